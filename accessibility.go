@@ -0,0 +1,183 @@
+package mermaid
+
+import (
+	"encoding/xml"
+	"strings"
+)
+
+// accessibility holds the accessible title and description
+// declared in a Mermaid diagram with accTitle and accDescr.
+type accessibility struct {
+	Title       string
+	Description string
+}
+
+// parseAccessibility extracts the accTitle and accDescr
+// declarations from the source of a Mermaid diagram.
+//
+// Both single line and multi-line forms are supported:
+//
+//	accTitle: Title of the diagram
+//	accDescr: Single line description
+//	accDescr {
+//	    Multi-line
+//	    description
+//	}
+func parseAccessibility(src string) accessibility {
+	var (
+		acc       accessibility
+		multiline []string
+		inDescr   bool
+	)
+	for _, line := range strings.Split(src, "\n") {
+		line = strings.TrimSpace(line)
+		if inDescr {
+			if before, ok := strings.CutSuffix(line, "}"); ok {
+				multiline = append(multiline, strings.TrimSpace(before))
+				acc.Description = strings.TrimSpace(strings.Join(multiline, "\n"))
+				inDescr = false
+				continue
+			}
+			multiline = append(multiline, line)
+			continue
+		}
+
+		if rest, ok := cutKeyword(line, "accTitle"); ok {
+			if value, ok := strings.CutPrefix(rest, ":"); ok {
+				acc.Title = strings.TrimSpace(value)
+			}
+			continue
+		}
+
+		rest, ok := cutKeyword(line, "accDescr")
+		if !ok {
+			continue
+		}
+		if value, ok := strings.CutPrefix(rest, ":"); ok {
+			acc.Description = strings.TrimSpace(value)
+		} else if value, ok := strings.CutPrefix(rest, "{"); ok {
+			value = strings.TrimSpace(value)
+			if before, ok := strings.CutSuffix(value, "}"); ok {
+				// accDescr { single line }
+				acc.Description = strings.TrimSpace(before)
+				continue
+			}
+
+			inDescr = true
+			multiline = multiline[:0]
+			if len(value) > 0 {
+				multiline = append(multiline, value)
+			}
+		}
+	}
+	return acc
+}
+
+// cutKeyword reports whether line starts with the given keyword
+// and returns the remainder of the line without leading spaces.
+func cutKeyword(line, keyword string) (rest string, ok bool) {
+	rest, ok = strings.CutPrefix(line, keyword)
+	if !ok {
+		return "", false
+	}
+	return strings.TrimLeft(rest, " \t"), true
+}
+
+// Fence attributes that specify the accessible title
+// and description of a diagram.
+//
+//	```mermaid {alt="Checkout flow" desc="How orders are placed"}
+var (
+	_attrTitle = []byte("title")
+	_attrAlt   = []byte("alt")
+	_attrDesc  = []byte("desc")
+)
+
+// blockAccessibility returns the accessible title and description
+// of a Block.
+//
+// Fence attributes take precedence over
+// accTitle and accDescr declarations in the diagram.
+func blockAccessibility(n *Block, src []byte) accessibility {
+	acc := parseAccessibility(string(n.source(src)))
+	for _, name := range [][]byte{_attrTitle, _attrAlt} {
+		if title, ok := attributeString(n, name); ok && len(strings.TrimSpace(title)) > 0 {
+			acc.Title = strings.TrimSpace(title)
+			break
+		}
+	}
+	if desc, ok := attributeString(n, _attrDesc); ok && len(strings.TrimSpace(desc)) > 0 {
+		acc.Description = strings.TrimSpace(desc)
+	}
+	return acc
+}
+
+// Name returns the accessible name of the diagram:
+// its title, or its description if it doesn't have a title.
+func (acc accessibility) Name() string {
+	if len(acc.Title) > 0 {
+		return acc.Title
+	}
+	return acc.Description
+}
+
+// AltText returns the alt text for an image of the diagram:
+// its accessible name, or a generic label if it doesn't have one.
+func (acc accessibility) AltText() string {
+	if name := acc.Name(); len(name) > 0 {
+		return name
+	}
+	return "Mermaid diagram"
+}
+
+// makeSVGAccessible marks the root <svg> element of doc with role="img"
+// and labels it with <title> and <desc> elements
+// holding the accessible name and description.
+//
+// Existing <title> and <desc> elements at the top of the SVG,
+// like the ones Mermaid generates for accTitle and accDescr,
+// are reused.
+// Both are given IDs starting with id
+// so that they're unique on the page.
+func makeSVGAccessible(doc *SVGDocument, acc accessibility, id string) {
+	root := doc.Root()
+	root.SetAttribute("role", "img")
+	root.RemoveAttribute("aria-labelledby")
+	root.RemoveAttribute("aria-describedby")
+
+	title, desc := acc.Name(), acc.Description
+	if title == desc {
+		// Don't repeat the description if it's used as the name.
+		desc = ""
+	}
+
+	// Drop existing labels. We'll add them back in the right order.
+	children := root.Children[:0]
+	for _, c := range root.Children {
+		if e, ok := c.(*SVGElement); ok && (e.Name.Local == "title" || e.Name.Local == "desc") {
+			continue
+		}
+		children = append(children, c)
+	}
+
+	var labels []SVGNode
+	if len(title) > 0 {
+		titleID := id + "-title"
+		labels = append(labels, svgTextElement("title", titleID, title))
+		root.SetAttribute("aria-labelledby", titleID)
+	}
+	if len(desc) > 0 {
+		descID := id + "-desc"
+		labels = append(labels, svgTextElement("desc", descID, desc))
+		root.SetAttribute("aria-describedby", descID)
+	}
+	root.Children = append(labels, children...)
+}
+
+func svgTextElement(name, id, text string) *SVGElement {
+	return &SVGElement{
+		Name:     xml.Name{Local: name},
+		Attr:     []xml.Attr{{Name: xml.Name{Local: "id"}, Value: id}},
+		Children: []SVGNode{&SVGText{Data: text}},
+	}
+}
@@ -0,0 +1,134 @@
+package mermaid
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseAccessibility(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc string
+		give string
+		want accessibility
+	}{
+		{desc: "empty"},
+		{
+			desc: "none",
+			give: unlines("graph TD;", "A-->B;"),
+		},
+		{
+			desc: "single line",
+			give: unlines(
+				"graph TD;",
+				"  accTitle: Big decisions",
+				"  accDescr: An overview of important decisions",
+				"  A-->B;",
+			),
+			want: accessibility{
+				Title:       "Big decisions",
+				Description: "An overview of important decisions",
+			},
+		},
+		{
+			desc: "multi-line description",
+			give: unlines(
+				"graph TD;",
+				"  accDescr {",
+				"    First line",
+				"    second line",
+				"  }",
+				"  A-->B;",
+			),
+			want: accessibility{
+				Description: "First line\nsecond line",
+			},
+		},
+		{
+			desc: "braces on one line",
+			give: unlines(
+				"pie",
+				"  accDescr { Pets adopted }",
+			),
+			want: accessibility{
+				Description: "Pets adopted",
+			},
+		},
+		{
+			desc: "not a keyword",
+			give: unlines(
+				"graph TD;",
+				"  accTitleX: nope",
+			),
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.desc, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.want, parseAccessibility(tt.give))
+		})
+	}
+}
+
+func TestMakeSVGAccessible(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc string
+		give string
+		acc  accessibility
+		want string
+	}{
+		{
+			desc: "no labels",
+			give: `<svg id="my-svg"><g/></svg>`,
+			acc:  accessibility{Title: "Flow", Description: "How it flows"},
+			want: `<svg id="my-svg" role="img" aria-labelledby="d-title" aria-describedby="d-desc">` +
+				`<title id="d-title">Flow</title><desc id="d-desc">How it flows</desc><g/></svg>`,
+		},
+		{
+			desc: "mermaid labels",
+			give: `<svg id="my-svg" role="graphics-document document" aria-labelledby="chart-title-my-svg" aria-describedby="chart-desc-my-svg">` +
+				`<title id="chart-title-my-svg">Old</title><desc id="chart-desc-my-svg">Old desc</desc><g/></svg>`,
+			acc: accessibility{Title: "New"},
+			want: `<svg id="my-svg" role="img" aria-labelledby="d-title">` +
+				`<title id="d-title">New</title><g/></svg>`,
+		},
+		{
+			desc: "description only",
+			give: `<svg/>`,
+			acc:  accessibility{Description: "Pets adopted"},
+			want: `<svg role="img" aria-labelledby="d-title"><title id="d-title">Pets adopted</title></svg>`,
+		},
+		{
+			desc: "escaping",
+			give: `<svg/>`,
+			acc:  accessibility{Title: "A < B & C"},
+			want: `<svg role="img" aria-labelledby="d-title"><title id="d-title">A &lt; B &amp; C</title></svg>`,
+		},
+		{
+			desc: "nothing",
+			give: `<svg aria-labelledby="x"><title id="x">Old</title></svg>`,
+			want: `<svg role="img"></svg>`,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.desc, func(t *testing.T) {
+			t.Parallel()
+
+			doc, err := ParseSVG(tt.give)
+			require.NoError(t, err)
+
+			makeSVGAccessible(doc, tt.acc, "d")
+			assert.Equal(t, tt.want, doc.String())
+		})
+	}
+}
@@ -0,0 +1,322 @@
+package mermaid
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"image/png"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// _defaultAssetURLPrefix is the default URL prefix
+// for diagrams written with an AssetWriter.
+const _defaultAssetURLPrefix = "/assets/mermaid/"
+
+// AssetWriter writes compiled diagrams as standalone files
+// so that they may be referenced from the document
+// instead of being inlined into it.
+//
+// Use it with [ServerRenderer.Assets].
+//
+// Names are derived from a hash of the file contents,
+// so writing the same name twice writes the same contents.
+// Implementations must be safe for concurrent use.
+type AssetWriter interface {
+	WriteAsset(ctx context.Context, name string, data []byte) error
+}
+
+// assetName returns a content-addressed name for an asset
+// with the given contents and extension.
+func assetName(data []byte, ext string) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:8]) + ext
+}
+
+// DirAssetWriter is an [AssetWriter] that writes assets
+// to a directory on disk.
+//
+//	&mermaid.DirAssetWriter{Dir: "public/assets/mermaid"}
+type DirAssetWriter struct {
+	// Dir is the directory to write assets to.
+	// It is created if it doesn't exist.
+	//
+	// This must be set.
+	Dir string
+}
+
+var _ AssetWriter = (*DirAssetWriter)(nil)
+
+// WriteAsset writes an asset to the directory.
+// Assets that already exist are left as-is.
+func (d *DirAssetWriter) WriteAsset(_ context.Context, name string, data []byte) (err error) {
+	if len(d.Dir) == 0 {
+		return errors.New("asset directory must be specified")
+	}
+	if !fs.ValidPath(name) || name == "." || strings.Contains(name, "/") {
+		return fmt.Errorf("invalid asset name %q", name)
+	}
+
+	target := filepath.Join(d.Dir, name)
+	if _, err := os.Stat(target); err == nil {
+		// Content-addressed names: it's already up-to-date.
+		return nil
+	}
+
+	if err := os.MkdirAll(d.Dir, 0o755); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(d.Dir, name+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = os.Remove(f.Name()) // ignore error
+		}
+	}()
+
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := os.Chmod(f.Name(), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), target)
+}
+
+// AssetFS is an in-memory [AssetWriter]
+// that also implements [fs.FS].
+//
+// Use it to serve assets without writing them to disk.
+//
+//	assets := new(mermaid.AssetFS)
+//	http.Handle("/assets/mermaid/",
+//		http.StripPrefix("/assets/mermaid/", http.FileServer(http.FS(assets))))
+//
+// The zero value is ready to use.
+type AssetFS struct {
+	mu    sync.RWMutex
+	files map[string][]byte
+}
+
+var (
+	_ AssetWriter  = (*AssetFS)(nil)
+	_ fs.ReadDirFS = (*AssetFS)(nil)
+)
+
+// WriteAsset stores an asset in memory.
+func (a *AssetFS) WriteAsset(_ context.Context, name string, data []byte) error {
+	if !fs.ValidPath(name) || name == "." || strings.Contains(name, "/") {
+		return fmt.Errorf("invalid asset name %q", name)
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if a.files == nil {
+		a.files = make(map[string][]byte)
+	}
+	a.files[name] = bytes.Clone(data)
+	return nil
+}
+
+// Open opens the named asset.
+// "." opens a directory listing all assets.
+func (a *AssetFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	if name == "." {
+		entries, err := a.ReadDir(name)
+		if err != nil {
+			return nil, err
+		}
+		return &assetDir{entries: entries}, nil
+	}
+
+	a.mu.RLock()
+	data, ok := a.files[name]
+	a.mu.RUnlock()
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+
+	return &assetFile{
+		info:   assetInfo{name: name, size: int64(len(data))},
+		Reader: bytes.NewReader(data),
+	}, nil
+}
+
+// ReadDir lists the assets.
+// Only "." is a valid directory.
+func (a *AssetFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if name != "." {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+
+	a.mu.RLock()
+	defer a.mu.RUnlock()
+
+	entries := make([]fs.DirEntry, 0, len(a.files))
+	for name, data := range a.files {
+		entries = append(entries, fs.FileInfoToDirEntry(assetInfo{
+			name: name,
+			size: int64(len(data)),
+		}))
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].Name() < entries[j].Name()
+	})
+	return entries, nil
+}
+
+type assetFile struct {
+	*bytes.Reader
+
+	info assetInfo
+}
+
+func (f *assetFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *assetFile) Close() error               { return nil }
+
+type assetDir struct {
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *assetDir) Stat() (fs.FileInfo, error) { return assetInfo{name: ".", dir: true}, nil }
+func (d *assetDir) Close() error               { return nil }
+
+func (d *assetDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: ".", Err: errors.New("is a directory")}
+}
+
+func (d *assetDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	entries := d.entries[d.offset:]
+	if n > 0 {
+		if len(entries) == 0 {
+			return nil, io.EOF
+		}
+		if n < len(entries) {
+			entries = entries[:n]
+		}
+	}
+	d.offset += len(entries)
+	return entries, nil
+}
+
+type assetInfo struct {
+	name string
+	size int64
+	dir  bool
+}
+
+var _ fs.FileInfo = assetInfo{}
+
+func (i assetInfo) Name() string       { return path.Base(i.name) }
+func (i assetInfo) Size() int64        { return i.size }
+func (i assetInfo) ModTime() time.Time { return time.Time{} }
+func (i assetInfo) IsDir() bool        { return i.dir }
+func (i assetInfo) Sys() any           { return nil }
+
+func (i assetInfo) Mode() fs.FileMode {
+	if i.dir {
+		return fs.ModeDir | 0o555
+	}
+	return 0o444
+}
+
+// diagramImage is a compiled diagram
+// to be referenced from an <img> tag.
+type diagramImage struct {
+	Data []byte
+	Ext  string // file extension including the "."
+	MIME string
+
+	// Display size of the image in CSS pixels.
+	// Zero if unknown.
+	Width, Height int
+}
+
+func svgImage(svg string) *diagramImage {
+	img := &diagramImage{
+		Data: []byte(svg),
+		Ext:  ".svg",
+		MIME: "image/svg+xml",
+	}
+	if w, h, ok := svgSize(svg); ok {
+		img.Width, img.Height = w, h
+	}
+	return img
+}
+
+// pngImage builds a diagramImage for a PNG
+// rendered at the given scale.
+func pngImage(data []byte, scale float64) *diagramImage {
+	img := &diagramImage{
+		Data: data,
+		Ext:  ".png",
+		MIME: "image/png",
+	}
+	if scale <= 0 {
+		scale = 1
+	}
+	if cfg, err := png.DecodeConfig(bytes.NewReader(data)); err == nil {
+		img.Width = int(float64(cfg.Width)/scale + 0.5)
+		img.Height = int(float64(cfg.Height)/scale + 0.5)
+	}
+	return img
+}
+
+// svgSize reports the intrinsic width and height of an SVG image
+// based on its viewBox attribute.
+// It reports false if the size could not be determined.
+func svgSize(svg string) (width, height int, ok bool) {
+	dec := xml.NewDecoder(strings.NewReader(svg))
+	for {
+		tok, err := dec.RawToken()
+		if err != nil {
+			return 0, 0, false
+		}
+
+		start, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		if start.Name.Local != "svg" {
+			return 0, 0, false
+		}
+
+		for _, attr := range start.Attr {
+			if attr.Name.Local != "viewBox" {
+				continue
+			}
+
+			w, h, ok := parseViewBox(attr.Value)
+			if !ok {
+				return 0, 0, false
+			}
+			return int(w + 0.5), int(h + 0.5), true
+		}
+		return 0, 0, false
+	}
+}
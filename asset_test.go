@@ -0,0 +1,127 @@
+package mermaid
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirAssetWriter(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	dir := filepath.Join(t.TempDir(), "assets")
+	w := &DirAssetWriter{Dir: dir}
+
+	require.NoError(t, w.WriteAsset(ctx, "abc.svg", []byte("<svg></svg>")))
+	require.NoError(t, w.WriteAsset(ctx, "abc.svg", []byte("<svg></svg>")),
+		"writing an existing asset must not fail")
+
+	got, err := os.ReadFile(filepath.Join(dir, "abc.svg"))
+	require.NoError(t, err)
+	assert.Equal(t, "<svg></svg>", string(got))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1, "temporary files must be cleaned up")
+}
+
+func TestDirAssetWriter_errors(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	t.Run("no directory", func(t *testing.T) {
+		t.Parallel()
+
+		err := new(DirAssetWriter).WriteAsset(ctx, "abc.svg", nil)
+		assert.ErrorContains(t, err, "asset directory must be specified")
+	})
+
+	t.Run("bad name", func(t *testing.T) {
+		t.Parallel()
+
+		w := &DirAssetWriter{Dir: t.TempDir()}
+		for _, name := range []string{"../abc.svg", "a/b.svg", "."} {
+			assert.ErrorContains(t, w.WriteAsset(ctx, name, nil), "invalid asset name", "name: %q", name)
+		}
+	})
+}
+
+func TestAssetFS(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	assets := new(AssetFS)
+	require.NoError(t, assets.WriteAsset(ctx, "a.svg", []byte("<svg>a</svg>")))
+	require.NoError(t, assets.WriteAsset(ctx, "b.svg", []byte("<svg>b</svg>")))
+	assert.ErrorContains(t, assets.WriteAsset(ctx, "c/d.svg", nil), "invalid asset name")
+
+	require.NoError(t, fstest.TestFS(assets, "a.svg", "b.svg"))
+
+	f, err := assets.Open("a.svg")
+	require.NoError(t, err)
+	defer func() {
+		assert.NoError(t, f.Close())
+	}()
+
+	info, err := f.Stat()
+	require.NoError(t, err)
+	assert.Equal(t, "a.svg", info.Name())
+	assert.Equal(t, int64(len("<svg>a</svg>")), info.Size())
+}
+
+func TestSVGSize(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		give  string
+		wantW int
+		wantH int
+		ok    bool
+	}{
+		{
+			name:  "viewBox",
+			give:  `<svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 120.5 80"><g/></svg>`,
+			wantW: 121,
+			wantH: 80,
+			ok:    true,
+		},
+		{
+			name:  "commas",
+			give:  `<svg viewBox="-8,-8,100,50"></svg>`,
+			wantW: 100,
+			wantH: 50,
+			ok:    true,
+		},
+		{
+			name:  "leading declaration",
+			give:  `<?xml version="1.0"?><svg viewBox="0 0 10 20"></svg>`,
+			wantW: 10,
+			wantH: 20,
+			ok:    true,
+		},
+		{name: "no viewBox", give: `<svg width="100%"></svg>`},
+		{name: "bad viewBox", give: `<svg viewBox="0 0 wide tall"></svg>`},
+		{name: "not svg", give: `<div></div>`},
+		{name: "empty"},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			w, h, ok := svgSize(tt.give)
+			assert.Equal(t, tt.ok, ok)
+			assert.Equal(t, tt.wantW, w)
+			assert.Equal(t, tt.wantH, h)
+		})
+	}
+}
@@ -1,6 +1,11 @@
 package mermaid
 
-import "github.com/yuin/goldmark/ast"
+import (
+	"bytes"
+	"context"
+
+	"github.com/yuin/goldmark/ast"
+)
 
 // Kind is the node kind of a Mermaid [Block] node.
 var Kind = ast.NewNodeKind("MermaidBlock")
@@ -18,11 +23,66 @@ var Kind = ast.NewNodeKind("MermaidBlock")
 // Its raw contents are the plain text of the Mermaid diagram.
 type Block struct {
 	ast.BaseBlock
+
+	// ctx is the context for compiling this block,
+	// specified with WithContext.
+	// This is nil if a context wasn't specified.
+	ctx context.Context
+
+	// errs records the errors collected by ErrorCollector
+	// for this block's conversion.
+	// This is nil if the block wasn't created by Transformer.
+	errs *collectedErrors
+
+	// fallback is set by ServerRenderer
+	// if this block was rendered client-side
+	// because it failed to compile.
+	fallback bool
+
+	// failed is set by ServerRenderer
+	// if this block failed to compile
+	// and was handled by an ErrorHandler.
+	failed bool
+
+	// result is set by ServerRenderer
+	// after this block has been compiled.
+	result *compileResult
+
+	// theme is the theme stylesheet extracted from the compiled SVG.
+	// It is set by ServerRenderer if SharedStyles is enabled.
+	theme *themeStyle
+
+	// counted is set by ServerRenderer
+	// once this block's document has been checked against MaxDiagrams.
+	counted bool
+
+	// svgID is the prefix for IDs inside the compiled SVG.
+	// It is set by ServerRenderer if UniqueIDs is enabled.
+	svgID string
 }
 
 // IsRaw reports that this block should be rendered as-is.
 func (*Block) IsRaw() bool { return true }
 
+// source returns the raw contents of this block.
+func (b *Block) source(src []byte) []byte {
+	var buff bytes.Buffer
+	lines := b.Lines()
+	for i := 0; i < lines.Len(); i++ {
+		line := lines.At(i)
+		buff.Write(line.Value(src))
+	}
+	return buff.Bytes()
+}
+
+// context returns the context for compiling this block.
+func (b *Block) context() context.Context {
+	if b.ctx == nil {
+		return context.Background()
+	}
+	return b.ctx
+}
+
 // Kind reports that this is a MermaidBlock.
 func (*Block) Kind() ast.NodeKind { return Kind }
 
@@ -51,3 +111,26 @@ func (*ScriptBlock) Kind() ast.NodeKind { return ScriptKind }
 func (b *ScriptBlock) Dump(src []byte, level int) {
 	ast.DumpHelper(b, src, level, nil, nil)
 }
+
+// StyleKind is the node kind of a Mermaid [StyleBlock] node.
+var StyleKind = ast.NewNodeKind("MermaidStyleBlock")
+
+// StyleBlock marks where the stylesheet shared by diagrams
+// rendered server-side will be included.
+// See ServerRenderer.SharedStyles.
+//
+// This is a placeholder and does not contain anything.
+type StyleBlock struct {
+	ast.BaseBlock
+}
+
+// IsRaw reports that this block should be rendered as-is.
+func (*StyleBlock) IsRaw() bool { return true }
+
+// Kind reports that this is a MermaidStyleBlock.
+func (*StyleBlock) Kind() ast.NodeKind { return StyleKind }
+
+// Dump dumps the contents of this block to stdout.
+func (b *StyleBlock) Dump(src []byte, level int) {
+	ast.DumpHelper(b, src, level, nil, nil)
+}
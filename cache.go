@@ -0,0 +1,317 @@
+package mermaid
+
+import (
+	"container/list"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+)
+
+// Cache stores the results of compiling Mermaid diagrams.
+// It is used with [CachingCompiler].
+//
+// Keys are hex-encoded hashes safe for use as file names.
+// Values are opaque byte slices.
+//
+// Implementations must be safe for concurrent use.
+type Cache interface {
+	// Get retrieves the value for the given key.
+	// It reports false if the key isn't in the cache.
+	Get(ctx context.Context, key string) (value []byte, ok bool, err error)
+
+	// Set stores a value for the given key.
+	Set(ctx context.Context, key string, value []byte) error
+}
+
+// CacheKeyer may be implemented by a [Compiler]
+// to identify its configuration for [CachingCompiler].
+//
+// Compilers that produce different output
+// for the same [CompileRequest] must return different keys.
+// For example, compilers configured with different themes.
+//
+// ctx is the context of the Compile call that needs the key.
+type CacheKeyer interface {
+	CacheKey(ctx context.Context) string
+}
+
+// _cacheVersion is included in all cache keys.
+// Change it to invalidate existing caches
+// if the format of cached values changes.
+const _cacheVersion = "goldmark-mermaid/v2"
+
+// CachingCompiler is a [Compiler] that caches the results
+// of another Compiler.
+//
+// Results are keyed by a hash of the [CompileRequest]
+// and the identity of the wrapped Compiler.
+// If the Compiler implements [CacheKeyer], that is its identity.
+// Otherwise, only its type is used:
+// differently configured instances of the same type
+// will share cache entries.
+// Implement CacheKeyer on such compilers.
+//
+//	compiler := &mermaid.CachingCompiler{
+//		Compiler: &mermaid.CLICompiler{},
+//		Cache:    &mermaid.DirCache{Dir: ".cache/mermaid"},
+//	}
+//
+// Failed compilations are not cached.
+type CachingCompiler struct {
+	// Compiler is the compiler whose results are cached.
+	//
+	// This must be set.
+	Compiler Compiler
+
+	// Cache stores compiled diagrams.
+	//
+	// This must be set.
+	// Use MemoryCache, DirCache, or your own implementation.
+	Cache Cache
+
+	// OnError, if set, is called when Cache fails
+	// to read or write a compiled diagram.
+	// Such errors don't fail compilation:
+	// read errors are treated as cache misses,
+	// and diagrams that can't be written are compiled again next time.
+	OnError func(error)
+
+	hits, misses atomic.Int64
+}
+
+var _ Compiler = (*CachingCompiler)(nil)
+
+// Compile compiles the provided Mermaid diagram,
+// re-using a previously compiled result if available.
+func (c *CachingCompiler) Compile(ctx context.Context, req *CompileRequest) (*CompileResponse, error) {
+	key, err := c.key(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if value, ok, err := c.Cache.Get(ctx, key); err != nil {
+		c.reportError(fmt.Errorf("read from cache: %w", err))
+	} else if ok {
+		var res CompileResponse
+		// If the cached value is corrupt, we'll compile it again
+		// and overwrite it.
+		if err := json.Unmarshal(value, &res); err == nil {
+			c.hits.Add(1)
+			return &res, nil
+		}
+	}
+
+	c.misses.Add(1)
+	res, err := c.Compiler.Compile(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	value, err := json.Marshal(res)
+	if err == nil {
+		err = c.Cache.Set(ctx, key, value)
+	}
+	if err != nil {
+		c.reportError(fmt.Errorf("write to cache: %w", err))
+	}
+	return res, nil
+}
+
+func (c *CachingCompiler) reportError(err error) {
+	if c.OnError != nil {
+		c.OnError(err)
+	}
+}
+
+// Hits reports the number of diagrams that were found in the cache.
+func (c *CachingCompiler) Hits() int64 { return c.hits.Load() }
+
+// Misses reports the number of diagrams that were not found in the cache
+// and had to be compiled.
+func (c *CachingCompiler) Misses() int64 { return c.misses.Load() }
+
+func (c *CachingCompiler) key(ctx context.Context, req *CompileRequest) (string, error) {
+	identity := fmt.Sprintf("%T", c.Compiler)
+	if k, ok := c.Compiler.(CacheKeyer); ok {
+		identity += " " + k.CacheKey(ctx)
+	}
+
+	b, err := json.Marshal(req)
+	if err != nil {
+		return "", fmt.Errorf("encode cache key: %w", err)
+	}
+
+	h := sha256.New()
+	_, _ = h.Write([]byte(_cacheVersion))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write([]byte(identity))
+	_, _ = h.Write([]byte{0})
+	_, _ = h.Write(b)
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// _defaultMemoryCacheSize is the default number of entries
+// held by MemoryCache.
+const _defaultMemoryCacheSize = 256
+
+// MemoryCache is an in-memory [Cache]
+// that holds a bounded number of entries.
+// When full, the least recently used entry is evicted.
+//
+// The zero value is ready to use.
+type MemoryCache struct {
+	// Size is the maximum number of entries in the cache.
+	//
+	// Defaults to 256.
+	Size int
+
+	mu      sync.Mutex
+	order   list.List                // of *memoryCacheEntry; front is most recent
+	entries map[string]*list.Element // key => element in order
+}
+
+var _ Cache = (*MemoryCache)(nil)
+
+type memoryCacheEntry struct {
+	key   string
+	value []byte
+}
+
+// Get retrieves the value for the given key.
+func (m *MemoryCache) Get(_ context.Context, key string) ([]byte, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	e, ok := m.entries[key]
+	if !ok {
+		return nil, false, nil
+	}
+	m.order.MoveToFront(e)
+	return e.Value.(*memoryCacheEntry).value, true, nil
+}
+
+// Set stores a value for the given key,
+// evicting the least recently used entry if the cache is full.
+func (m *MemoryCache) Set(_ context.Context, key string, value []byte) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if e, ok := m.entries[key]; ok {
+		e.Value.(*memoryCacheEntry).value = value
+		m.order.MoveToFront(e)
+		return nil
+	}
+
+	if m.entries == nil {
+		m.entries = make(map[string]*list.Element)
+	}
+
+	size := m.Size
+	if size <= 0 {
+		size = _defaultMemoryCacheSize
+	}
+	for m.order.Len() >= size {
+		oldest := m.order.Back()
+		m.order.Remove(oldest)
+		delete(m.entries, oldest.Value.(*memoryCacheEntry).key)
+	}
+
+	m.entries[key] = m.order.PushFront(&memoryCacheEntry{
+		key:   key,
+		value: value,
+	})
+	return nil
+}
+
+// Len reports the number of entries in the cache.
+func (m *MemoryCache) Len() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	return m.order.Len()
+}
+
+// DirCache is a [Cache] that stores entries as files in a directory.
+// Entries survive process restarts.
+//
+// DirCache does not evict entries.
+// Delete the directory to clear the cache.
+type DirCache struct {
+	// Dir is the directory holding the cache.
+	// It is created if it doesn't exist.
+	//
+	// This must be set.
+	Dir string
+}
+
+var _ Cache = (*DirCache)(nil)
+
+// Get retrieves the value for the given key.
+func (d *DirCache) Get(_ context.Context, key string) ([]byte, bool, error) {
+	path, err := d.path(key)
+	if err != nil {
+		return nil, false, err
+	}
+
+	value, err := os.ReadFile(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil, false, nil
+		}
+		return nil, false, err
+	}
+	return value, true, nil
+}
+
+// Set stores a value for the given key.
+//
+// The file is written atomically
+// so that concurrent readers never see a partial entry.
+func (d *DirCache) Set(_ context.Context, key string, value []byte) (err error) {
+	path, err := d.path(key)
+	if err != nil {
+		return err
+	}
+
+	if err := os.MkdirAll(d.Dir, 0o755); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(d.Dir, key+".*.tmp")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err != nil {
+			_ = os.Remove(f.Name()) // ignore error
+		}
+	}()
+
+	_, err = f.Write(value)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
+
+func (d *DirCache) path(key string) (string, error) {
+	if len(d.Dir) == 0 {
+		return "", errors.New("cache directory must be specified")
+	}
+	if !filepath.IsLocal(key) || filepath.Base(key) != key {
+		return "", fmt.Errorf("invalid cache key %q", key)
+	}
+	return filepath.Join(d.Dir, key+".json"), nil
+}
@@ -0,0 +1,315 @@
+package mermaid
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCachingCompiler(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name  string
+		cache func(t *testing.T) Cache
+	}{
+		{
+			name: "memory",
+			cache: func(*testing.T) Cache {
+				return new(MemoryCache)
+			},
+		},
+		{
+			name: "dir",
+			cache: func(t *testing.T) Cache {
+				return &DirCache{Dir: filepath.Join(t.TempDir(), "cache")}
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var calls int
+			compiler := compilerStub{
+				CompileF: func(_ context.Context, req *CompileRequest) (*CompileResponse, error) {
+					calls++
+					if req.Source == "bad" {
+						return nil, errors.New("great sadness")
+					}
+					return &CompileResponse{SVG: "<svg>" + req.Source + "</svg>"}, nil
+				},
+			}
+
+			c := &CachingCompiler{
+				Compiler: &compiler,
+				Cache:    tt.cache(t),
+			}
+			ctx := context.Background()
+
+			for i := 0; i < 3; i++ {
+				res, err := c.Compile(ctx, &CompileRequest{Source: "A -> B"})
+				require.NoError(t, err)
+				assert.Equal(t, "<svg>A -> B</svg>", res.SVG)
+			}
+			assert.Equal(t, 1, calls)
+			assert.Equal(t, int64(2), c.Hits())
+			assert.Equal(t, int64(1), c.Misses())
+
+			res, err := c.Compile(ctx, &CompileRequest{Source: "C -> D"})
+			require.NoError(t, err)
+			assert.Equal(t, "<svg>C -> D</svg>", res.SVG)
+			assert.Equal(t, 2, calls)
+
+			// Failures aren't cached.
+			for i := 0; i < 2; i++ {
+				_, err := c.Compile(ctx, &CompileRequest{Source: "bad"})
+				assert.ErrorContains(t, err, "great sadness")
+			}
+			assert.Equal(t, 4, calls)
+			assert.Equal(t, int64(2), c.Hits())
+			assert.Equal(t, int64(4), c.Misses())
+		})
+	}
+}
+
+func TestCachingCompiler_cacheErrors(t *testing.T) {
+	t.Parallel()
+
+	var calls int
+	compiler := compilerStub{
+		CompileF: func(_ context.Context, req *CompileRequest) (*CompileResponse, error) {
+			calls++
+			return &CompileResponse{SVG: "<svg>" + req.Source + "</svg>"}, nil
+		},
+	}
+
+	var cacheErrs []error
+	c := &CachingCompiler{
+		Compiler: &compiler,
+		Cache: &failingCache{
+			getErr: errors.New("permission denied"),
+			setErr: errors.New("disk full"),
+		},
+		OnError: func(err error) {
+			cacheErrs = append(cacheErrs, err)
+		},
+	}
+
+	for i := 0; i < 2; i++ {
+		res, err := c.Compile(context.Background(), &CompileRequest{Source: "A -> B"})
+		require.NoError(t, err)
+		assert.Equal(t, "<svg>A -> B</svg>", res.SVG)
+	}
+	assert.Equal(t, 2, calls)
+	assert.Equal(t, int64(0), c.Hits())
+	assert.Equal(t, int64(2), c.Misses())
+
+	require.Len(t, cacheErrs, 4)
+	assert.EqualError(t, cacheErrs[0], "read from cache: permission denied")
+	assert.EqualError(t, cacheErrs[1], "write to cache: disk full")
+
+	// Without OnError, errors are dropped.
+	c.OnError = nil
+	_, err := c.Compile(context.Background(), &CompileRequest{Source: "A -> B"})
+	assert.NoError(t, err)
+}
+
+// failingCache is a Cache that fails all reads and writes.
+type failingCache struct{ getErr, setErr error }
+
+func (c *failingCache) Get(context.Context, string) ([]byte, bool, error) {
+	return nil, false, c.getErr
+}
+
+func (c *failingCache) Set(context.Context, string, []byte) error {
+	return c.setErr
+}
+
+func TestCachingCompiler_metadata(t *testing.T) {
+	t.Parallel()
+
+	want := &CompileResponse{
+		SVG:         `<svg viewBox="0 0 20 10"></svg>`,
+		Width:       20,
+		Height:      10,
+		ViewBox:     "0 0 20 10",
+		DiagramType: "flowchart-v2",
+		Title:       "Title",
+		Description: "Description",
+		Duration:    time.Second,
+		Warnings:    []string{"warning"},
+	}
+	compiler := compilerStub{
+		CompileF: func(context.Context, *CompileRequest) (*CompileResponse, error) {
+			return want, nil
+		},
+	}
+
+	c := &CachingCompiler{
+		Compiler: &compiler,
+		Cache:    new(MemoryCache),
+	}
+	for i := 0; i < 2; i++ {
+		res, err := c.Compile(context.Background(), &CompileRequest{Source: "A -> B"})
+		require.NoError(t, err)
+		assert.Equal(t, want, res)
+	}
+	assert.Equal(t, int64(1), c.Hits())
+}
+
+func TestCachingCompiler_key(t *testing.T) {
+	t.Parallel()
+
+	req := &CompileRequest{Source: "A -> B"}
+	key := func(compiler Compiler) string {
+		k, err := (&CachingCompiler{Compiler: compiler}).key(context.Background(), req)
+		require.NoError(t, err)
+		return k
+	}
+
+	plain := key(&CLICompiler{})
+	assert.Equal(t, plain, key(&CLICompiler{}), "keys must be stable")
+	assert.NotEqual(t, plain, key(&CLICompiler{Theme: "dark"}),
+		"compiler configuration must be part of the key")
+	assert.NotEqual(t, plain, key(new(compilerStub)),
+		"compiler type must be part of the key")
+
+	k, err := (&CachingCompiler{Compiler: &CLICompiler{}}).key(context.Background(), &CompileRequest{Source: "C -> D"})
+	require.NoError(t, err)
+	assert.NotEqual(t, plain, k, "source must be part of the key")
+}
+
+func TestCachingCompiler_corruptEntry(t *testing.T) {
+	t.Parallel()
+
+	cache := &DirCache{Dir: t.TempDir()}
+	c := &CachingCompiler{
+		Compiler: &compilerStub{
+			CompileF: func(context.Context, *CompileRequest) (*CompileResponse, error) {
+				return &CompileResponse{SVG: "<svg></svg>"}, nil
+			},
+		},
+		Cache: cache,
+	}
+
+	req := &CompileRequest{Source: "A -> B"}
+	key, err := c.key(context.Background(), req)
+	require.NoError(t, err)
+	require.NoError(t, cache.Set(context.Background(), key, []byte("not json")))
+
+	res, err := c.Compile(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, "<svg></svg>", res.SVG)
+	assert.Equal(t, int64(1), c.Misses())
+
+	// The corrupt entry was replaced.
+	_, err = c.Compile(context.Background(), req)
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), c.Hits())
+}
+
+func TestMemoryCache_evicts(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	cache := &MemoryCache{Size: 2}
+	require.NoError(t, cache.Set(ctx, "a", []byte("1")))
+	require.NoError(t, cache.Set(ctx, "b", []byte("2")))
+
+	// Use "a" so that "b" is the least recently used.
+	_, ok, err := cache.Get(ctx, "a")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	require.NoError(t, cache.Set(ctx, "c", []byte("3")))
+	assert.Equal(t, 2, cache.Len())
+
+	_, ok, err = cache.Get(ctx, "b")
+	require.NoError(t, err)
+	assert.False(t, ok, "b should have been evicted")
+
+	for _, key := range []string{"a", "c"} {
+		_, ok, err := cache.Get(ctx, key)
+		require.NoError(t, err)
+		assert.True(t, ok, "%v should still be in the cache", key)
+	}
+}
+
+func TestMemoryCache_concurrent(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	cache := &MemoryCache{Size: 10}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			key := strconv.Itoa(i % 20)
+			assert.NoError(t, cache.Set(ctx, key, []byte(key)))
+			_, _, err := cache.Get(ctx, key)
+			assert.NoError(t, err)
+		}(i)
+	}
+	wg.Wait()
+
+	assert.Equal(t, 10, cache.Len())
+}
+
+func TestDirCache(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+	dir := filepath.Join(t.TempDir(), "cache")
+
+	_, ok, err := (&DirCache{Dir: dir}).Get(ctx, "abc")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	require.NoError(t, (&DirCache{Dir: dir}).Set(ctx, "abc", []byte("hello")))
+
+	// A new DirCache for the same directory sees the entry.
+	value, ok, err := (&DirCache{Dir: dir}).Get(ctx, "abc")
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "hello", string(value))
+
+	entries, err := os.ReadDir(dir)
+	require.NoError(t, err)
+	if assert.Len(t, entries, 1, "temporary files must be cleaned up") {
+		assert.Equal(t, "abc.json", entries[0].Name())
+	}
+}
+
+func TestDirCache_errors(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.Background()
+
+	t.Run("no directory", func(t *testing.T) {
+		t.Parallel()
+
+		_, _, err := new(DirCache).Get(ctx, "abc")
+		assert.ErrorContains(t, err, "cache directory must be specified")
+	})
+
+	t.Run("bad key", func(t *testing.T) {
+		t.Parallel()
+
+		err := (&DirCache{Dir: t.TempDir()}).Set(ctx, "../abc", nil)
+		assert.ErrorContains(t, err, "invalid cache key")
+	})
+}
@@ -3,9 +3,16 @@ package mermaid
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 )
 
 // CLI provides access to the MermaidJS CLI.
@@ -55,18 +62,132 @@ type CLICompiler struct {
 	//
 	// Values include "dark", "default", "forest", and "neutral".
 	// See MermaidJS documentation for a full list.
+	//
+	// CompileRequest.Theme takes precedence over this.
 	Theme string
 }
 
-var _ Compiler = (*CLICompiler)(nil)
+var (
+	_ Compiler   = (*CLICompiler)(nil)
+	_ CacheKeyer = (*CLICompiler)(nil)
+)
+
+// CacheKey identifies the configuration of this compiler
+// for [CachingCompiler].
+//
+// This includes the location of the MermaidJS CLI
+// and the version it reports,
+// so that diagrams compiled by different installations
+// don't share cache entries.
+// The version is retrieved by running "mmdc --version" with ctx
+// the first time it's needed for each installation.
+func (d *CLICompiler) CacheKey(ctx context.Context) string {
+	mmdc := DefaultCLI
+	if d.CLI != nil {
+		mmdc = d.CLI
+	}
+	return cliIdentity(ctx, mmdc) + " theme=" + d.Theme
+}
+
+// _cliVersions holds the versions reported by MermaidJS CLIs
+// so that "mmdc --version" runs only once for each.
+//
+// Keys are the resolved paths of mmdcCLIs,
+// and the CLI values themselves for other comparable implementations.
+var _cliVersions sync.Map // any => string
+
+// cliIdentity identifies the given MermaidJS CLI installation:
+// its resolved path if known, and the version it reports.
+// Parts that can't be determined are omitted.
+func cliIdentity(ctx context.Context, mmdc CLI) string {
+	name := fmt.Sprintf("%T", mmdc)
+	var versionKey any
+	if c, ok := mmdc.(*mmdcCLI); ok {
+		name = resolveCLIPath(c.Path)
+		versionKey = name
+	} else if reflect.ValueOf(mmdc).Comparable() {
+		versionKey = mmdc
+	}
+
+	identity := "cli=" + name
+	if version := cliVersion(ctx, mmdc, versionKey); len(version) > 0 {
+		identity += " version=" + version
+	}
+	return identity
+}
+
+// cliVersion returns the version reported by the given MermaidJS CLI,
+// or an empty string if it can't be determined.
+//
+// Successful results are remembered under key if it's non-nil.
+func cliVersion(ctx context.Context, mmdc CLI, key any) string {
+	if key != nil {
+		if version, ok := _cliVersions.Load(key); ok {
+			return version.(string)
+		}
+	}
+
+	out, err := mmdc.CommandContext(ctx, "--version").Output()
+	if err != nil {
+		// Try again next time.
+		// This may have failed because ctx was canceled.
+		return ""
+	}
+
+	version := strings.TrimSpace(string(out))
+	if key != nil {
+		_cliVersions.Store(key, version)
+	}
+	return version
+}
+
+// resolveCLIPath returns the absolute path to the given mmdc executable
+// with symbolic links resolved.
+// For example, node_modules/.bin/mmdc resolves to the file
+// in the installed @mermaid-js/mermaid-cli package.
+//
+// If the path can't be resolved, it's returned as-is.
+func resolveCLIPath(path string) string {
+	if path == "" {
+		path = "mmdc"
+	}
+
+	resolved, err := exec.LookPath(path)
+	if err != nil {
+		return path
+	}
+	if p, err := filepath.EvalSymlinks(resolved); err == nil {
+		resolved = p
+	}
+	if p, err := filepath.Abs(resolved); err == nil {
+		resolved = p
+	}
+	return resolved
+}
 
-// Compile compiles the provided Mermaid diagram into an SVG.
+// Compile compiles the provided Mermaid diagram into an image.
+// FormatSVG, FormatPNG, and FormatPDF are supported.
+//
+// The response includes metadata about the diagram only for FormatSVG.
+// Mermaid warnings are not reported.
 func (d *CLICompiler) Compile(ctx context.Context, req *CompileRequest) (_ *CompileResponse, err error) {
 	mmdc := DefaultCLI
 	if d.CLI != nil {
 		mmdc = d.CLI
 	}
 
+	var ext string
+	switch req.Format {
+	case FormatSVG:
+		ext = "svg"
+	case FormatPNG:
+		ext = "png"
+	case FormatPDF:
+		ext = "pdf"
+	default:
+		return nil, fmt.Errorf("unsupported format %v", req.Format)
+	}
+
 	input, err := os.CreateTemp("", "in.*.mermaid")
 	if err != nil {
 		return nil, err
@@ -83,7 +204,7 @@ func (d *CLICompiler) Compile(ctx context.Context, req *CompileRequest) (_ *Comp
 		return nil, fmt.Errorf("write input: %w", err)
 	}
 
-	output, err := os.CreateTemp("", "out.*.svg")
+	output, err := os.CreateTemp("", "out.*."+ext)
 	if err != nil {
 		return nil, err
 	}
@@ -97,11 +218,44 @@ func (d *CLICompiler) Compile(ctx context.Context, req *CompileRequest) (_ *Comp
 	args := []string{
 		"--input", input.Name(),
 		"--output", output.Name(),
-		"--outputFormat", "svg",
+		"--outputFormat", ext,
 		"--quiet",
 	}
-	if len(d.Theme) > 0 {
-		args = append(args, "--theme", d.Theme)
+	theme := d.Theme
+	if len(req.Theme) > 0 {
+		theme = req.Theme
+	}
+	if len(theme) > 0 {
+		args = append(args, "--theme", theme)
+	}
+	if len(req.Background) > 0 {
+		args = append(args, "--backgroundColor", req.Background)
+	}
+	if len(req.ID) > 0 {
+		args = append(args, "--svgId", req.ID)
+	}
+	if req.Width > 0 {
+		args = append(args, "--width", strconv.Itoa(req.Width))
+	}
+	if req.Height > 0 {
+		args = append(args, "--height", strconv.Itoa(req.Height))
+	}
+	if req.Scale > 0 {
+		args = append(args, "--scale", strconv.FormatFloat(req.Scale, 'f', -1, 64))
+	}
+	if cfg := req.MermaidConfig(); cfg != nil {
+		configFile, err := writeCLIConfig(cfg)
+		if err != nil {
+			return nil, err
+		}
+		defer func() {
+			_ = os.Remove(configFile) // ignore error
+		}()
+		args = append(args, "--configFile", configFile)
+	}
+	if req.Format == FormatPDF {
+		// Size the page to the diagram instead of using A4.
+		args = append(args, "--pdfFit")
 	}
 
 	cmd := mmdc.CommandContext(ctx, args...)
@@ -121,15 +275,50 @@ func (d *CLICompiler) Compile(ctx context.Context, req *CompileRequest) (_ *Comp
 		cmd.Stderr = &cmdout
 	}
 
+	start := time.Now()
 	if err := cmd.Run(); err != nil {
+		// If the context ended, mmdc was killed.
+		// Report why alongside the exit status.
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return nil, fmt.Errorf("mmdc: %w: %w", ctxErr, err)
+		}
 		return nil, fmt.Errorf("mmdc: %w", err)
 	}
+	duration := time.Since(start)
 
 	out, err := os.ReadFile(output.Name())
 	if err != nil {
-		return nil, fmt.Errorf("read svg: %w", err)
+		return nil, fmt.Errorf("read %v: %w", ext, err)
+	}
+
+	if req.Format != FormatSVG {
+		return &CompileResponse{Data: out, Duration: duration}, nil
+	}
+
+	res := &CompileResponse{
+		SVG:      string(out),
+		Duration: duration,
+	}
+	setSVGMetadata(res, res.SVG)
+	return res, nil
+}
+
+// writeCLIConfig writes the configuration to a temporary file
+// and returns its path.
+// The caller must remove the file.
+func writeCLIConfig(cfg map[string]any) (string, error) {
+	f, err := os.CreateTemp("", "config.*.json")
+	if err != nil {
+		return "", err
+	}
+
+	err = json.NewEncoder(f).Encode(cfg)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		_ = os.Remove(f.Name()) // ignore error
+		return "", fmt.Errorf("write config: %w", err)
 	}
-	return &CompileResponse{
-		SVG: string(out),
-	}, nil
+	return f.Name(), nil
 }
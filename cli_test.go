@@ -6,7 +6,10 @@ import (
 	"fmt"
 	"log"
 	"os"
+	"os/exec"
+	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -111,12 +114,350 @@ func TestCLICompiler_Error_NoOutput(t *testing.T) {
 	assert.ErrorContains(t, err, "output:\nintentional no-op")
 }
 
+func TestCLICompiler_PNG(t *testing.T) {
+	t.Parallel()
+
+	mmdc := exectest.Act(t, func() {
+		opts, err := parseMermaidOpts(os.Args[1:])
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if want, got := "png", opts.OutputFormat; want != got {
+			log.Fatalf("unexpected output format: want %q, got %q", want, got)
+		}
+		if want, got := ".png", filepath.Ext(opts.Output); want != got {
+			log.Fatalf("unexpected output extension: want %q, got %q", want, got)
+		}
+		if want, got := 800, opts.Width; want != got {
+			log.Fatalf("unexpected width: want %v, got %v", want, got)
+		}
+		if want, got := 2.5, opts.Scale; want != got {
+			log.Fatalf("unexpected scale: want %v, got %v", want, got)
+		}
+
+		if err := os.WriteFile(opts.Output, []byte("\x89PNG"), 0o644); err != nil {
+			log.Fatal(err)
+		}
+	})
+
+	c := CLICompiler{CLI: mmdc}
+	res, err := c.Compile(context.Background(), &CompileRequest{
+		Source: `A -> B`,
+		Format: FormatPNG,
+		Width:  800,
+		Scale:  2.5,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []byte("\x89PNG"), res.Data)
+	assert.Empty(t, res.SVG)
+}
+
+func TestCLICompiler_size(t *testing.T) {
+	t.Parallel()
+
+	mmdc := exectest.Act(t, func() {
+		opts, err := parseMermaidOpts(os.Args[1:])
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if want, got := 640, opts.Width; want != got {
+			log.Fatalf("unexpected width: want %v, got %v", want, got)
+		}
+		if want, got := 480, opts.Height; want != got {
+			log.Fatalf("unexpected height: want %v, got %v", want, got)
+		}
+		if want, got := 2.0, opts.Scale; want != got {
+			log.Fatalf("unexpected scale: want %v, got %v", want, got)
+		}
+
+		if err := os.WriteFile(opts.Output, []byte("<svg></svg>"), 0o644); err != nil {
+			log.Fatal(err)
+		}
+	})
+
+	c := CLICompiler{CLI: mmdc}
+	res, err := c.Compile(context.Background(), &CompileRequest{
+		Source: `A -> B`,
+		Width:  640,
+		Height: 480,
+		Scale:  2,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, "<svg></svg>", res.SVG)
+}
+
+func TestCLICompiler_limits(t *testing.T) {
+	t.Parallel()
+
+	mmdc := exectest.Act(t, func() {
+		opts, err := parseMermaidOpts(os.Args[1:])
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if opts.ConfigFile == "" {
+			log.Fatal("expected --configFile")
+		}
+		config, err := os.ReadFile(opts.ConfigFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if err := os.WriteFile(opts.Output, config, 0o644); err != nil {
+			log.Fatal(err)
+		}
+	})
+
+	c := CLICompiler{CLI: mmdc}
+	res, err := c.Compile(context.Background(), &CompileRequest{
+		Source:      `A -> B`,
+		MaxTextSize: 1000,
+		MaxEdges:    50,
+	})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"maxTextSize": 1000, "maxEdges": 50}`, res.SVG)
+}
+
+func TestCLICompiler_deterministic(t *testing.T) {
+	t.Parallel()
+
+	mmdc := exectest.Act(t, func() {
+		opts, err := parseMermaidOpts(os.Args[1:])
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		config, err := os.ReadFile(opts.ConfigFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if err := os.WriteFile(opts.Output, config, 0o644); err != nil {
+			log.Fatal(err)
+		}
+	})
+
+	c := CLICompiler{CLI: mmdc}
+	res, err := c.Compile(context.Background(), &CompileRequest{
+		Source:              `A -> B`,
+		DeterministicIDSeed: "abc",
+	})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"deterministicIds": true,
+		"deterministicIDSeed": "abc",
+		"gantt": {"todayMarker": "off"}
+	}`, res.SVG)
+}
+
+func TestCLICompiler_requestOptions(t *testing.T) {
+	t.Parallel()
+
+	mmdc := exectest.Act(t, func() {
+		opts, err := parseMermaidOpts(os.Args[1:])
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if want, got := "dark", opts.Theme; want != got {
+			log.Fatalf("unexpected theme: want %q, got %q", want, got)
+		}
+		if want, got := "transparent", opts.BackgroundColor; want != got {
+			log.Fatalf("unexpected background color: want %q, got %q", want, got)
+		}
+		if want, got := "diagram", opts.SVGID; want != got {
+			log.Fatalf("unexpected SVG ID: want %q, got %q", want, got)
+		}
+
+		config, err := os.ReadFile(opts.ConfigFile)
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if err := os.WriteFile(opts.Output, config, 0o644); err != nil {
+			log.Fatal(err)
+		}
+	})
+
+	// Theme in the request takes precedence over the compiler's.
+	c := CLICompiler{CLI: mmdc, Theme: "forest"}
+	res, err := c.Compile(context.Background(), &CompileRequest{
+		Source:     `A -> B`,
+		ID:         "diagram",
+		Theme:      "dark",
+		Background: "transparent",
+		Config: map[string]any{
+			"flowchart": map[string]any{"curve": "linear"},
+		},
+	})
+	require.NoError(t, err)
+	assert.JSONEq(t, `{
+		"theme": "dark",
+		"flowchart": {"curve": "linear"}
+	}`, res.SVG)
+}
+
+func TestCLICompiler_metadata(t *testing.T) {
+	t.Parallel()
+
+	const svg = `<svg id="my-svg" viewBox="0 0 200 100" aria-roledescription="sequence">` +
+		`<title>Login</title><desc>How users log in</desc><g/></svg>`
+	mmdc := exectest.Act(t, func() {
+		opts, err := parseMermaidOpts(os.Args[1:])
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if err := os.WriteFile(opts.Output, []byte(svg), 0o644); err != nil {
+			log.Fatal(err)
+		}
+	})
+
+	c := CLICompiler{CLI: mmdc}
+	res, err := c.Compile(context.Background(), &CompileRequest{
+		Source: `sequenceDiagram`,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, svg, res.SVG)
+	assert.Equal(t, 200.0, res.Width)
+	assert.Equal(t, 100.0, res.Height)
+	assert.Equal(t, "0 0 200 100", res.ViewBox)
+	assert.Equal(t, "sequence", res.DiagramType)
+	assert.Equal(t, "Login", res.Title)
+	assert.Equal(t, "How users log in", res.Description)
+	assert.Positive(t, res.Duration)
+	assert.Empty(t, res.Warnings)
+}
+
+func TestCLICompiler_timeout(t *testing.T) {
+	t.Parallel()
+
+	mmdc := exectest.Act(t, func() {
+		time.Sleep(time.Minute)
+	})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	c := CLICompiler{CLI: mmdc}
+	_, err := c.Compile(ctx, &CompileRequest{Source: `A -> B`})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+
+	var exitErr *exec.ExitError
+	assert.ErrorAs(t, err, &exitErr)
+}
+
+func TestCLICompiler_CacheKey(t *testing.T) {
+	t.Parallel()
+
+	mmdc := exectest.Act(t, func() {
+		if len(os.Args) != 2 || os.Args[1] != "--version" {
+			log.Fatalf("unexpected arguments: %q", os.Args[1:])
+		}
+		fmt.Println("10.9.1")
+	})
+
+	c := CLICompiler{CLI: mmdc, Theme: "dark"}
+
+	// The version isn't known if the context is canceled,
+	// and isn't remembered.
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+	assert.Equal(t, "cli=*exectest.Actor theme=dark", c.CacheKey(canceled))
+
+	ctx := context.Background()
+	key := c.CacheKey(ctx)
+	assert.Equal(t, "cli=*exectest.Actor version=10.9.1 theme=dark", key)
+	assert.Equal(t, key, c.CacheKey(ctx), "keys must be stable")
+
+	// Remembered for the CLI, not the compiler.
+	assert.Equal(t, key, (&CLICompiler{CLI: mmdc, Theme: "dark"}).CacheKey(canceled))
+}
+
+func TestCLICompiler_CacheKey_path(t *testing.T) {
+	t.Parallel()
+
+	// Neither of these exist so the version is unknown.
+	dir := t.TempDir()
+	a := filepath.Join(dir, "a", "mmdc")
+	b := filepath.Join(dir, "b", "mmdc")
+
+	ctx := context.Background()
+	keyA := (&CLICompiler{CLI: MMDC(a)}).CacheKey(ctx)
+	keyB := (&CLICompiler{CLI: MMDC(b)}).CacheKey(ctx)
+	assert.Equal(t, "cli="+a+" theme=", keyA)
+	assert.NotEqual(t, keyA, keyB)
+}
+
+func TestResolveCLIPath(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	target := filepath.Join(dir, "cli.js")
+	require.NoError(t, os.WriteFile(target, []byte("#!/bin/sh\n"), 0o755))
+
+	link := filepath.Join(dir, "mmdc")
+	if err := os.Symlink(target, link); err != nil {
+		t.Skipf("symlinks not supported: %v", err)
+	}
+
+	want, err := filepath.EvalSymlinks(target)
+	require.NoError(t, err)
+	assert.Equal(t, want, resolveCLIPath(link))
+}
+
+func TestCLICompiler_PDF(t *testing.T) {
+	t.Parallel()
+
+	mmdc := exectest.Act(t, func() {
+		opts, err := parseMermaidOpts(os.Args[1:])
+		if err != nil {
+			log.Fatal(err)
+		}
+
+		if want, got := "pdf", opts.OutputFormat; want != got {
+			log.Fatalf("unexpected output format: want %q, got %q", want, got)
+		}
+		if !opts.PDFFit {
+			log.Fatal("expected --pdfFit")
+		}
+
+		if err := os.WriteFile(opts.Output, []byte("%PDF-1.7"), 0o644); err != nil {
+			log.Fatal(err)
+		}
+	})
+
+	got, err := CompilePDF(context.Background(), &CLICompiler{CLI: mmdc}, `A -> B`)
+	require.NoError(t, err)
+	assert.Equal(t, "%PDF-1.7", string(got))
+}
+
+func TestCLICompiler_UnsupportedFormat(t *testing.T) {
+	t.Parallel()
+
+	c := CLICompiler{CLI: MMDC("/bin/false")}
+	_, err := c.Compile(context.Background(), &CompileRequest{
+		Source: `A -> B`,
+		Format: Format(42),
+	})
+	assert.ErrorContains(t, err, "unsupported format Format(42)")
+}
+
 type mermaidOpts struct {
-	Input        string
-	Output       string
-	OutputFormat string
-	Theme        string
-	Quiet        bool
+	Input           string
+	Output          string
+	OutputFormat    string
+	Theme           string
+	BackgroundColor string
+	SVGID           string
+	Width           int
+	Height          int
+	Scale           float64
+	ConfigFile      string
+	PDFFit          bool
+	Quiet           bool
 }
 
 // parseMermaidOpts is a helper used in tests pretending to be the mmdc CLI.
@@ -127,6 +468,13 @@ func parseMermaidOpts(args []string) (*mermaidOpts, error) {
 	flag.StringVar(&o.Output, "output", "", "")
 	flag.StringVar(&o.Theme, "theme", "", "")
 	flag.StringVar(&o.OutputFormat, "outputFormat", "", "")
+	flag.StringVar(&o.BackgroundColor, "backgroundColor", "", "")
+	flag.StringVar(&o.SVGID, "svgId", "", "")
+	flag.IntVar(&o.Width, "width", 0, "")
+	flag.IntVar(&o.Height, "height", 0, "")
+	flag.Float64Var(&o.Scale, "scale", 0, "")
+	flag.StringVar(&o.ConfigFile, "configFile", "", "")
+	flag.BoolVar(&o.PDFFit, "pdfFit", false, "")
 	flag.BoolVar(&o.Quiet, "quiet", false, "")
 	err := flag.Parse(args)
 	return &o, err
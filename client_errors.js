@@ -0,0 +1,54 @@
+async function (opts) {
+	const template = document.getElementById(opts.template);
+
+	// Renders each diagram separately so that one bad diagram
+	// does not prevent the others from rendering.
+	for (const node of document.querySelectorAll(opts.selector)) {
+		if (node.getAttribute('data-processed')) {
+			continue;
+		}
+
+		const source = node.textContent;
+		try {
+			await mermaid.run({ nodes: [node] });
+		} catch (err) {
+			node.replaceWith(errorPanel(template, source, err));
+		}
+	}
+
+	function errorPanel(template, source, err) {
+		const message = (err && err.message) || String(err);
+
+		let line = '';
+		const lineno = errorLine(err, message);
+		if (lineno > 0) {
+			line = source.split('\n')[lineno - 1] || '';
+		}
+
+		const panel = template.content.cloneNode(true);
+		for (const slot of panel.querySelectorAll('[data-mermaid-error]')) {
+			switch (slot.getAttribute('data-mermaid-error')) {
+				case 'message':
+					slot.textContent = message;
+					break;
+				case 'line':
+					slot.textContent = line;
+					if (!line) {
+						slot.hidden = true;
+					}
+					break;
+			}
+		}
+		return panel;
+	}
+
+	// errorLine reports the 1-indexed line number of the error,
+	// or 0 if it isn't known.
+	function errorLine(err, message) {
+		if (err && err.hash && err.hash.loc) {
+			return err.hash.loc.first_line;
+		}
+		const m = /line (\d+)/i.exec(message);
+		return m ? parseInt(m[1], 10) : 0;
+	}
+}
@@ -1,8 +1,12 @@
 package mermaid
 
 import (
+	_ "embed" // for go:embed
 	"encoding/json"
+	"errors"
+	"fmt"
 	"html/template"
+	"strings"
 
 	"github.com/yuin/goldmark/ast"
 	"github.com/yuin/goldmark/renderer"
@@ -11,6 +15,20 @@ import (
 
 const _defaultMermaidJS = "https://cdn.jsdelivr.net/npm/mermaid/dist/mermaid.min.js"
 
+// DefaultErrorTemplate is the HTML used to report diagrams
+// that failed to render when [ClientRenderer.ShowErrors] is set.
+const DefaultErrorTemplate = `<div class="mermaid-error" role="alert" ` +
+	`style="border:1px solid #d33;border-radius:4px;background:#fff5f5;color:#900;padding:0.5em 1em;">` +
+	`<p data-mermaid-error="message" style="margin:0;white-space:pre-wrap;"></p>` +
+	`<pre data-mermaid-error="line" style="margin:0.5em 0 0;"></pre>` +
+	`</div>`
+
+// ID of the <template> element holding the error panel.
+const _errorTemplateID = "mermaid-error-template"
+
+//go:embed client_errors.js
+var _clientErrorsJS string
+
 // ClientRenderer renders Mermaid diagrams as HTML,
 // to be rendered into images client side.
 //
@@ -29,11 +47,156 @@ type ClientRenderer struct {
 	// Defaults to "pre".
 	ContainerTag string
 
+	// ContainerClass is the class attribute of the container.
+	// Classes specified on the fenced code block are added to it.
+	//
+	// MermaidJS is told to render elements matching this class.
+	//
+	// Defaults to "mermaid".
+	ContainerClass string
+
+	// ContainerAttributes are additional attributes
+	// for the container element.
+	// For example, data-* attributes.
+	ContainerAttributes map[string]string
+
 	// Theme is the Mermaid theme to use.
 	//
 	// This is passed onto 'mermaid.initialize'
 	// as part of the client-side rendering.
 	Theme string
+
+	// ShowErrors reports diagrams that fail to render
+	// by replacing them with an error panel
+	// that holds the error message and the offending line.
+	// Other diagrams on the page continue to render.
+	//
+	// Without this, Mermaid's default error graphic is shown
+	// or the diagram is left as-is.
+	ShowErrors bool
+
+	// ErrorTemplate is the HTML for the error panel
+	// used if ShowErrors is set.
+	//
+	// Elements inside the template with a data-mermaid-error attribute
+	// will have their text replaced:
+	// "message" is replaced with the error message,
+	// and "line" with the line of the diagram that caused the error.
+	// For example:
+	//
+	//	<div class="my-error">
+	//	  <p data-mermaid-error="message"></p>
+	//	  <code data-mermaid-error="line"></code>
+	//	</div>
+	//
+	// Defaults to DefaultErrorTemplate.
+	ErrorTemplate string
+
+	// PanZoom wraps rendered diagrams in a viewport
+	// that supports panning and zooming with the mouse,
+	// with buttons to zoom and to view the diagram full screen.
+	//
+	// The supporting script and stylesheet are included
+	// with the Mermaid <script> tag.
+	PanZoom bool
+
+	// Toolbar adds a toolbar to each diagram
+	// with buttons to view or copy the diagram source,
+	// and to download the diagram as an SVG or PNG image.
+	//
+	// The diagram, its toolbar, and a hidden copy of its source
+	// are wrapped in a <div class="mermaid-figure">.
+	// The supporting script and stylesheet are included
+	// with the Mermaid <script> tag.
+	Toolbar bool
+
+	// Callbacks are functions that diagrams can invoke
+	// with Mermaid's click interaction:
+	//
+	//	click nodeId callbackName
+	//
+	// These are defined on window before Mermaid renders the diagrams.
+	Callbacks []ClickCallback
+
+	// SecurityLevel is the Mermaid security level for diagrams.
+	// Values include "strict", "loose", "antiscript", and "sandbox".
+	// See MermaidJS documentation for details.
+	//
+	// Mermaid ignores click interactions with the "strict" level,
+	// so this defaults to "loose" if Callbacks are specified.
+	// Otherwise, Mermaid's default is used.
+	SecurityLevel string
+
+	// NoScriptFallback specifies what to render
+	// inside a <noscript> element after each diagram.
+	// Readers that don't run JavaScript will see this
+	// instead of the raw Mermaid source.
+	//
+	// Use NoScriptSVG, NoScriptImage, or NoScriptText.
+	// If unset, no <noscript> element is rendered.
+	NoScriptFallback NoScriptFallback
+}
+
+// ClickCallback is a JavaScript function that a Mermaid diagram
+// can invoke when a node is clicked.
+//
+// Only one of JS or URL may be set.
+type ClickCallback struct {
+	// Name of the function as referenced from the diagram.
+	Name string
+
+	// JS is the body of the JavaScript function.
+	// The ID of the clicked node is available in it as 'nodeId'.
+	//
+	//	ClickCallback{
+	//		Name: "showDetails",
+	//		JS:   "openPanel(nodeId);",
+	//	}
+	JS string
+
+	// URL is a template for a URL to navigate to
+	// when the node is clicked.
+	// Occurrences of "{nodeId}" are replaced
+	// with the URL-encoded ID of the clicked node.
+	//
+	//	ClickCallback{
+	//		Name: "openService",
+	//		URL:  "/services/{nodeId}",
+	//	}
+	URL string
+}
+
+// writeFunc writes JavaScript that defines this callback on window.
+func (c *ClickCallback) writeFunc(w util.BufWriter) error {
+	if len(c.Name) == 0 {
+		return errors.New("click callback name must be specified")
+	}
+	if len(c.JS) > 0 && len(c.URL) > 0 {
+		return fmt.Errorf("click callback %q: only one of JS or URL may be specified", c.Name)
+	}
+
+	name, err := json.Marshal(c.Name)
+	if err != nil {
+		return fmt.Errorf("click callback %q: %w", c.Name, err)
+	}
+
+	_, _ = w.WriteString("window[")
+	_, _ = w.Write(name)
+	_, _ = w.WriteString("]=function(nodeId){")
+	if len(c.URL) > 0 {
+		url, err := json.Marshal(c.URL)
+		if err != nil {
+			return fmt.Errorf("click callback %q: %w", c.Name, err)
+		}
+
+		_, _ = w.WriteString("window.location.href=")
+		_, _ = w.Write(url)
+		_, _ = w.WriteString(`.split("{nodeId}").join(encodeURIComponent(nodeId));`)
+	} else {
+		_, _ = w.WriteString(c.JS)
+	}
+	_, _ = w.WriteString("};")
+	return nil
 }
 
 // RegisterFuncs registers the renderer for Mermaid blocks with the provided
@@ -45,16 +208,13 @@ func (r *ClientRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer)
 
 // Render renders mermaid.Block nodes.
 func (r *ClientRenderer) Render(w util.BufWriter, src []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
-	tag := r.ContainerTag
-	if len(tag) == 0 {
-		tag = "pre"
-	}
-
+	c := r.container()
 	n := node.(*Block)
 	if entering {
-		_, _ = w.WriteString("<")
-		template.HTMLEscape(w, []byte(tag))
-		_, _ = w.WriteString(` class="mermaid">`)
+		if r.Toolbar {
+			_, _ = w.WriteString(_toolbarOpen)
+		}
+		c.Open(w, n, "")
 
 		lines := n.Lines()
 		for i := 0; i < lines.Len(); i++ {
@@ -62,17 +222,50 @@ func (r *ClientRenderer) Render(w util.BufWriter, src []byte, node ast.Node, ent
 			template.HTMLEscape(w, line.Value(src))
 		}
 	} else {
-		_, _ = w.WriteString("</")
-		template.HTMLEscape(w, []byte(tag))
-		_, _ = w.WriteString(">")
+		c.Close(w)
+
+		// The <noscript> element must be outside the container
+		// because Mermaid reads the diagram from its text.
+		if r.NoScriptFallback != nil {
+			_, _ = w.WriteString("<noscript>")
+			if err := r.NoScriptFallback.RenderNoScript(n.context(), w, string(n.source(src))); err != nil {
+				return ast.WalkStop, fmt.Errorf("render noscript: %w", err)
+			}
+			_, _ = w.WriteString("</noscript>")
+		}
+
+		if r.Toolbar {
+			writeToolbarClose(w, n.source(src))
+		}
 	}
 	return ast.WalkContinue, nil
 }
 
+func (r *ClientRenderer) container() *container {
+	tag := r.ContainerTag
+	if len(tag) == 0 {
+		tag = "pre"
+	}
+
+	return &container{
+		Tag:        tag,
+		Class:      r.containerClass(),
+		Attributes: r.ContainerAttributes,
+	}
+}
+
+func (r *ClientRenderer) containerClass() string {
+	if len(r.ContainerClass) == 0 {
+		return _defaultContainerClass
+	}
+	return r.ContainerClass
+}
+
 // initializationOptions defines options for mermaid.initialize(..).
 type initializationOptions struct {
-	StartOnLoad bool   `json:"startOnLoad"`
-	Theme       string `json:"theme,omitempty"`
+	StartOnLoad   bool   `json:"startOnLoad"`
+	Theme         string `json:"theme,omitempty"`
+	SecurityLevel string `json:"securityLevel,omitempty"`
 }
 
 // RenderScript renders mermaid.ScriptBlock nodes.
@@ -87,19 +280,96 @@ func (r *ClientRenderer) RenderScript(w util.BufWriter, _ []byte, node ast.Node,
 		_, _ = w.WriteString(`<script src="`)
 		_, _ = w.WriteString(mermaidJS)
 		_, _ = w.WriteString(`"></script>`)
+		if r.ShowErrors {
+			errorTemplate := r.ErrorTemplate
+			if len(errorTemplate) == 0 {
+				errorTemplate = DefaultErrorTemplate
+			}
+
+			_, _ = w.WriteString(`<template id="` + _errorTemplateID + `">`)
+			_, _ = w.WriteString(errorTemplate)
+			_, _ = w.WriteString(`</template>`)
+		}
 	} else {
+		securityLevel := r.SecurityLevel
+		if len(securityLevel) == 0 && len(r.Callbacks) > 0 {
+			securityLevel = "loose"
+		}
+
+		// If we're reporting errors,
+		// or the containers use a different class,
+		// we'll run Mermaid ourselves.
+		selector := classSelector(r.containerClass())
+		startOnLoad := !r.ShowErrors && selector == "."+_defaultContainerClass
+
 		b, err := json.Marshal(initializationOptions{
-			StartOnLoad: true,
-			Theme:       r.Theme,
+			StartOnLoad:   startOnLoad,
+			Theme:         r.Theme,
+			SecurityLevel: securityLevel,
 		})
 		if err != nil {
 			return ast.WalkStop, err
 		}
 
-		_, _ = w.WriteString("<script>mermaid.initialize(")
+		_, _ = w.WriteString("<script>")
+		for i := range r.Callbacks {
+			if err := r.Callbacks[i].writeFunc(w); err != nil {
+				return ast.WalkStop, err
+			}
+		}
+		_, _ = w.WriteString("mermaid.initialize(")
 		_, _ = w.Write(b)
-		_, _ = w.WriteString(");</script>")
+		_, _ = w.WriteString(");")
+		if r.ShowErrors {
+			if err := r.writeRunScript(w, selector); err != nil {
+				return ast.WalkStop, err
+			}
+		} else if !startOnLoad {
+			q, err := json.Marshal(selector)
+			if err != nil {
+				return ast.WalkStop, err
+			}
+			_, _ = w.WriteString("mermaid.run({querySelector:")
+			_, _ = w.Write(q)
+			_, _ = w.WriteString("});")
+		}
+		_, _ = w.WriteString("</script>")
+
+		if r.PanZoom {
+			if err := writePanZoomAssets(w, panZoomOptions{Selector: selector}); err != nil {
+				return ast.WalkStop, err
+			}
+		}
+		if r.Toolbar {
+			writeToolbarAssets(w)
+		}
 	}
 
 	return ast.WalkContinue, nil
 }
+
+// runOptions defines options for the script
+// that runs Mermaid with error reporting.
+type runOptions struct {
+	Selector string `json:"selector"`
+	Template string `json:"template"`
+}
+
+// writeRunScript writes JavaScript that renders diagrams with mermaid.run
+// and reports failures with the error template.
+func (r *ClientRenderer) writeRunScript(w util.BufWriter, selector string) error {
+	b, err := json.Marshal(runOptions{
+		Selector: selector,
+		Template: _errorTemplateID,
+	})
+	if err != nil {
+		return err
+	}
+
+	_, _ = w.WriteString("(")
+	_, _ = w.WriteString(strings.TrimSpace(_clientErrorsJS))
+	_, _ = w.WriteString(")(")
+	_, _ = w.Write(b)
+	_, _ = w.WriteString(");")
+	return nil
+}
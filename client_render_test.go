@@ -2,10 +2,16 @@ package mermaid
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yuin/goldmark"
 	"github.com/yuin/goldmark/renderer"
 	"github.com/yuin/goldmark/text"
 	"github.com/yuin/goldmark/util"
@@ -145,3 +151,391 @@ func buildNodeRenderer(r renderer.NodeRenderer) renderer.Renderer {
 		),
 	)
 }
+
+func TestRenderer_Script_showErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc         string
+		template     string
+		wantTemplate string
+	}{
+		{
+			desc:         "default template",
+			wantTemplate: DefaultErrorTemplate,
+		},
+		{
+			desc:         "custom template",
+			template:     `<p class="oops" data-mermaid-error="message"></p>`,
+			wantTemplate: `<p class="oops" data-mermaid-error="message"></p>`,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.desc, func(t *testing.T) {
+			t.Parallel()
+
+			r := buildNodeRenderer(&ClientRenderer{
+				MermaidURL:    "mermaid.js",
+				ShowErrors:    true,
+				ErrorTemplate: tt.template,
+			})
+
+			var buff bytes.Buffer
+			require.NoError(t,
+				r.Render(&buff, nil /* src */, &ScriptBlock{}))
+
+			got := buff.String()
+			assert.Contains(t, got,
+				`<template id="mermaid-error-template">`+tt.wantTemplate+`</template>`)
+			assert.Contains(t, got, `mermaid.initialize({"startOnLoad":false});`)
+			assert.Contains(t, got, `await mermaid.run(`)
+			assert.Contains(t, got, `({"selector":".mermaid","template":"mermaid-error-template"});</script>`)
+		})
+	}
+}
+
+func TestRenderer_Script_panZoom(t *testing.T) {
+	t.Parallel()
+
+	r := buildNodeRenderer(&ClientRenderer{
+		MermaidURL: "mermaid.js",
+		PanZoom:    true,
+	})
+
+	var buff bytes.Buffer
+	require.NoError(t,
+		r.Render(&buff, nil /* src */, &ScriptBlock{}))
+
+	got := buff.String()
+	assert.True(t, strings.HasPrefix(got,
+		`<script src="mermaid.js"></script>`+
+			`<script>mermaid.initialize({"startOnLoad":true});</script>`+
+			`<style>`+_panZoomCSS+`</style>`), "got %q", got)
+	assert.True(t, strings.HasSuffix(got, `({"selector":".mermaid"});</script>`), "got %q", got)
+}
+
+func TestRenderer_Script_callbacks(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc          string
+		callbacks     []ClickCallback
+		securityLevel string
+		want          string
+	}{
+		{
+			desc: "js",
+			callbacks: []ClickCallback{
+				{Name: "showDetails", JS: "openPanel(nodeId);"},
+			},
+			want: `<script>window["showDetails"]=function(nodeId){openPanel(nodeId);};` +
+				`mermaid.initialize({"startOnLoad":true,"securityLevel":"loose"});</script>`,
+		},
+		{
+			desc: "url",
+			callbacks: []ClickCallback{
+				{Name: "open", URL: "/svc/{nodeId}?a=1&b=2"},
+			},
+			want: `<script>window["open"]=function(nodeId){` +
+				`window.location.href="/svc/{nodeId}?a=1\u0026b=2".split("{nodeId}").join(encodeURIComponent(nodeId));};` +
+				`mermaid.initialize({"startOnLoad":true,"securityLevel":"loose"});</script>`,
+		},
+		{
+			desc: "explicit security level",
+			callbacks: []ClickCallback{
+				{Name: "a", JS: "x();"},
+				{Name: "b", JS: "y();"},
+			},
+			securityLevel: "antiscript",
+			want: `<script>window["a"]=function(nodeId){x();};window["b"]=function(nodeId){y();};` +
+				`mermaid.initialize({"startOnLoad":true,"securityLevel":"antiscript"});</script>`,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.desc, func(t *testing.T) {
+			t.Parallel()
+
+			r := buildNodeRenderer(&ClientRenderer{
+				MermaidURL:    "mermaid.js",
+				Callbacks:     tt.callbacks,
+				SecurityLevel: tt.securityLevel,
+			})
+
+			var buff bytes.Buffer
+			require.NoError(t,
+				r.Render(&buff, nil /* src */, &ScriptBlock{}))
+			assert.Equal(t, `<script src="mermaid.js"></script>`+tt.want, buff.String())
+		})
+	}
+}
+
+func TestRenderer_Script_callbacksInvalid(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc     string
+		callback ClickCallback
+		wantErr  string
+	}{
+		{
+			desc:     "no name",
+			callback: ClickCallback{JS: "x();"},
+			wantErr:  "click callback name must be specified",
+		},
+		{
+			desc:     "js and url",
+			callback: ClickCallback{Name: "foo", JS: "x();", URL: "/foo"},
+			wantErr:  `click callback "foo": only one of JS or URL may be specified`,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.desc, func(t *testing.T) {
+			t.Parallel()
+
+			r := buildNodeRenderer(&ClientRenderer{
+				Callbacks: []ClickCallback{tt.callback},
+			})
+
+			var buff bytes.Buffer
+			err := r.Render(&buff, nil /* src */, &ScriptBlock{})
+			assert.ErrorContains(t, err, tt.wantErr)
+		})
+	}
+}
+
+func TestRenderer_NoScriptFallback(t *testing.T) {
+	t.Parallel()
+
+	src := unlines(
+		"graph TD;",
+		"accTitle: Flow <1>",
+		"accDescr: A goes to B",
+		"A-->B;",
+	)
+
+	compiler := compilerStub{
+		CompileF: func(ctx context.Context, req *CompileRequest) (*CompileResponse, error) {
+			switch req.Source {
+			case "bad":
+				return nil, errors.New("great sadness")
+			case "slow":
+				<-ctx.Done()
+				return nil, ctx.Err()
+			}
+			return &CompileResponse{SVG: "<svg></svg>"}, nil
+		},
+	}
+
+	tests := []struct {
+		desc     string
+		give     string
+		fallback NoScriptFallback
+		want     string
+	}{
+		{
+			desc:     "svg",
+			give:     src,
+			fallback: &NoScriptSVG{Compiler: &compiler},
+			want:     `<noscript><svg></svg></noscript>`,
+		},
+		{
+			desc:     "svg image",
+			give:     src,
+			fallback: &NoScriptSVG{Compiler: &compiler, Image: true},
+			want:     `<noscript><img src="data:image/svg+xml;base64,PHN2Zz48L3N2Zz4=" alt="Flow &lt;1&gt;"></noscript>`,
+		},
+		{
+			desc:     "svg compile error",
+			give:     "bad",
+			fallback: &NoScriptSVG{Compiler: &compiler},
+			want:     `<noscript></noscript>`,
+		},
+		{
+			desc:     "svg too large",
+			give:     src,
+			fallback: &NoScriptSVG{Compiler: &compiler, MaxSourceSize: 10},
+			want:     `<noscript><p><strong>Flow &lt;1&gt;</strong></p><p>A goes to B</p></noscript>`,
+		},
+		{
+			desc:     "svg timeout",
+			give:     "slow",
+			fallback: &NoScriptSVG{Compiler: &compiler, Timeout: time.Millisecond},
+			want:     `<noscript></noscript>`,
+		},
+		{
+			desc: "image",
+			give: src,
+			fallback: &NoScriptImage{
+				URL: func(string) string { return "/img/flow.svg?a=1&b=2" },
+			},
+			want: `<noscript><img src="/img/flow.svg?a=1&amp;b=2" alt="Flow &lt;1&gt;"></noscript>`,
+		},
+		{
+			desc: "image description",
+			give: "graph TD;\naccDescr: A goes to B",
+			fallback: &NoScriptImage{
+				URL: func(string) string { return "/img/flow.svg" },
+			},
+			want: `<noscript><img src="/img/flow.svg" alt="A goes to B"></noscript>`,
+		},
+		{
+			desc: "image unnamed",
+			give: "graph TD;",
+			fallback: &NoScriptImage{
+				URL: func(string) string { return "/img/flow.svg" },
+			},
+			want: `<noscript><img src="/img/flow.svg" alt="Mermaid diagram"></noscript>`,
+		},
+		{
+			desc:     "text",
+			give:     src,
+			fallback: new(NoScriptText),
+			want:     `<noscript><p><strong>Flow &lt;1&gt;</strong></p><p>A goes to B</p></noscript>`,
+		},
+		{
+			desc:     "text default",
+			give:     "graph TD;",
+			fallback: &NoScriptText{Default: "Enable JavaScript to view this diagram."},
+			want:     `<noscript><p>Enable JavaScript to view this diagram.</p></noscript>`,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.desc, func(t *testing.T) {
+			t.Parallel()
+
+			r := buildNodeRenderer(&ClientRenderer{
+				NoScriptFallback: tt.fallback,
+			})
+
+			reader := text.NewReader([]byte(tt.give))
+			give := blockFromReader(reader)
+
+			var buff bytes.Buffer
+			require.NoError(t, r.Render(&buff, reader.Source(), give), "Render")
+
+			got := buff.String()
+			_, got, ok := strings.Cut(got, "</pre>")
+			require.True(t, ok, "missing closing tag: %q", buff.String())
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestRenderer_NoScriptFallback_canceled(t *testing.T) {
+	t.Parallel()
+
+	compiler := compilerStub{
+		CompileF: func(ctx context.Context, _ *CompileRequest) (*CompileResponse, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	}
+
+	md := goldmark.New(
+		goldmark.WithExtensions(&Extender{
+			RenderMode:       RenderModeClient,
+			NoScriptFallback: &NoScriptSVG{Compiler: &compiler},
+		}),
+	)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	var buff bytes.Buffer
+	err := ConvertContext(ctx, md, []byte(unlines(
+		"```mermaid",
+		"graph",
+		"```",
+	)), &buff)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestRenderer_NoScriptFallback_imageNoURL(t *testing.T) {
+	t.Parallel()
+
+	r := buildNodeRenderer(&ClientRenderer{
+		NoScriptFallback: new(NoScriptImage),
+	})
+
+	reader := text.NewReader([]byte("graph TD;"))
+	give := blockFromReader(reader)
+
+	var buff bytes.Buffer
+	err := r.Render(&buff, reader.Source(), give)
+	assert.ErrorContains(t, err, "URL must be specified")
+}
+
+func TestRenderer_ContainerAttributes(t *testing.T) {
+	t.Parallel()
+
+	r := buildNodeRenderer(&ClientRenderer{
+		ContainerClass: "diagram",
+		ContainerAttributes: map[string]string{
+			"data-b": "2",
+			"data-a": `"1"`,
+		},
+	})
+
+	reader := text.NewReader([]byte("graph TD;"))
+	give := blockFromReader(reader)
+	give.SetAttributeString("id", []byte("flow"))
+	give.SetAttributeString("class", []byte("wide"))
+	give.SetAttributeString("data-owner", []byte("payments"))
+	give.SetAttributeString("width", []byte("100")) // not rendered
+
+	var buff bytes.Buffer
+	require.NoError(t, r.Render(&buff, reader.Source(), give), "Render")
+	assert.Equal(t,
+		`<pre id="flow" class="diagram wide" data-a="&#34;1&#34;" data-b="2" data-owner="payments">graph TD;</pre>`,
+		buff.String())
+}
+
+func TestRenderer_Script_containerClass(t *testing.T) {
+	t.Parallel()
+
+	r := buildNodeRenderer(&ClientRenderer{
+		MermaidURL:     "mermaid.js",
+		ContainerClass: "diagram wide",
+	})
+
+	var buff bytes.Buffer
+	require.NoError(t,
+		r.Render(&buff, nil /* src */, &ScriptBlock{}))
+	assert.Equal(t,
+		`<script src="mermaid.js"></script>`+
+			`<script>mermaid.initialize({"startOnLoad":false});`+
+			`mermaid.run({querySelector:".diagram.wide"});</script>`,
+		buff.String())
+}
+
+func TestRenderer_Toolbar(t *testing.T) {
+	t.Parallel()
+
+	r := buildNodeRenderer(&ClientRenderer{
+		Toolbar: true,
+	})
+
+	reader := text.NewReader([]byte("A -> B"))
+	give := blockFromReader(reader)
+
+	var buff bytes.Buffer
+	require.NoError(t, r.Render(&buff, reader.Source(), give), "Render")
+	assert.Equal(t,
+		_toolbarOpen+
+			`<pre class="mermaid">A -&gt; B</pre>`+
+			`<pre class="mermaid-source" hidden>A -&gt; B</pre></div>`,
+		buff.String())
+
+	buff.Reset()
+	require.NoError(t,
+		r.Render(&buff, nil /* src */, &ScriptBlock{}))
+	assert.Contains(t, buff.String(), "<style>"+_toolbarCSS+"</style>")
+}
@@ -0,0 +1,133 @@
+package mermaid
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"sort"
+	"strings"
+
+	"github.com/yuin/goldmark/ast"
+	"github.com/yuin/goldmark/util"
+)
+
+// Class of the container element for diagrams by default.
+const _defaultContainerClass = "mermaid"
+
+// _renderedClass marks containers holding diagrams
+// that were rendered server-side.
+//
+// Such containers also specify data-processed="true"
+// which tells MermaidJS that the diagram has already been rendered,
+// so pages that also load MermaidJS will leave them alone.
+const _renderedClass = "mermaid-rendered"
+
+var (
+	_attrID    = []byte("id")
+	_attrClass = []byte("class")
+	_attrData  = []byte("data-")
+)
+
+// container specifies how to render the element holding a diagram.
+type container struct {
+	// Tag is the name of the HTML tag.
+	Tag string
+
+	// Class is the value of the class attribute.
+	// Classes specified on the fenced code block are appended to it.
+	Class string
+
+	// Attributes are additional attributes on the element.
+	Attributes map[string]string
+}
+
+// Open writes the opening tag for the container of the given Block.
+//
+// The id, class, and data-* attributes of the Block are included.
+// extra is written as-is after all other attributes.
+func (c *container) Open(w util.BufWriter, n *Block, extra string) {
+	_, _ = w.WriteString("<")
+	template.HTMLEscape(w, []byte(c.Tag))
+
+	if id, ok := attributeString(n, _attrID); ok {
+		_, _ = w.WriteString(` id="`)
+		template.HTMLEscape(w, []byte(id))
+		_, _ = w.WriteString(`"`)
+	}
+
+	class := c.Class
+	if fenceClass, ok := attributeString(n, _attrClass); ok {
+		class = strings.TrimSpace(class + " " + fenceClass)
+	}
+	if len(class) > 0 {
+		_, _ = w.WriteString(` class="`)
+		template.HTMLEscape(w, []byte(class))
+		_, _ = w.WriteString(`"`)
+	}
+
+	names := make([]string, 0, len(c.Attributes))
+	for name := range c.Attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		writeAttribute(w, []byte(name), c.Attributes[name])
+	}
+
+	for _, attr := range n.Attributes() {
+		if bytes.HasPrefix(attr.Name, _attrData) {
+			writeAttribute(w, attr.Name, attributeValue(attr.Value))
+		}
+	}
+
+	_, _ = w.WriteString(extra)
+	_, _ = w.WriteString(">")
+}
+
+// Close writes the closing tag for the container.
+func (c *container) Close(w util.BufWriter) {
+	_, _ = w.WriteString("</")
+	template.HTMLEscape(w, []byte(c.Tag))
+	_, _ = w.WriteString(">")
+}
+
+// classSelector returns a CSS selector that matches elements
+// with all of the given classes.
+func classSelector(class string) string {
+	fields := strings.Fields(class)
+	if len(fields) == 0 {
+		return ""
+	}
+	return "." + strings.Join(fields, ".")
+}
+
+func writeAttribute(w util.BufWriter, name []byte, value string) {
+	_, _ = w.WriteString(" ")
+	template.HTMLEscape(w, name)
+	_, _ = w.WriteString(`="`)
+	template.HTMLEscape(w, []byte(value))
+	_, _ = w.WriteString(`"`)
+}
+
+// attributeString returns the value of the named attribute
+// of the node as a string.
+func attributeString(n ast.Node, name []byte) (string, bool) {
+	v, ok := n.Attribute(name)
+	if !ok {
+		return "", false
+	}
+	return attributeValue(v), true
+}
+
+// attributeValue converts the value of an attribute
+// parsed by goldmark into a string.
+func attributeValue(v any) string {
+	switch v := v.(type) {
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	default:
+		return fmt.Sprint(v)
+	}
+}
@@ -0,0 +1,52 @@
+package mermaid
+
+import (
+	"context"
+	"io"
+
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/parser"
+)
+
+var _contextKey = parser.NewContextKey()
+
+// WithContext is a Goldmark parse option
+// that specifies the context for compiling Mermaid diagrams
+// in a document server-side,
+// including those compiled by NoScriptSVG.
+// If the context is canceled, in-flight compilations are stopped
+// and the conversion fails with the context's error.
+//
+//	md.Convert(src, w, mermaid.WithContext(ctx))
+//
+// If you also pass parser.WithContext to Convert,
+// pass it before this option.
+// Alternatively, use [ConvertContext].
+func WithContext(ctx context.Context) parser.ParseOption {
+	return func(cfg *parser.ParseConfig) {
+		if cfg.Context == nil {
+			cfg.Context = parser.NewContext()
+		}
+		cfg.Context.Set(_contextKey, ctx)
+	}
+}
+
+// ConvertContext converts the Markdown source with the given Goldmark object,
+// using ctx when compiling Mermaid diagrams.
+//
+// It is equivalent to:
+//
+//	md.Convert(src, w, append(opts, mermaid.WithContext(ctx))...)
+func ConvertContext(ctx context.Context, md goldmark.Markdown, src []byte, w io.Writer, opts ...parser.ParseOption) error {
+	return md.Convert(src, w, append(opts, WithContext(ctx))...)
+}
+
+// contextFrom returns the context specified with WithContext,
+// or nil if one wasn't specified.
+func contextFrom(pc parser.Context) context.Context {
+	if pc == nil {
+		return nil
+	}
+	ctx, _ := pc.Get(_contextKey).(context.Context)
+	return ctx
+}
@@ -0,0 +1,69 @@
+package mermaid
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/parser"
+)
+
+type testContextKey struct{}
+
+func TestWithContext(t *testing.T) {
+	t.Parallel()
+
+	ctx := context.WithValue(context.Background(), testContextKey{}, "hello")
+
+	var got context.Context
+	compiler := compilerStub{
+		CompileF: func(ctx context.Context, _ *CompileRequest) (*CompileResponse, error) {
+			got = ctx
+			return &CompileResponse{SVG: "<svg></svg>"}, nil
+		},
+	}
+
+	md := goldmark.New(
+		goldmark.WithExtensions(&Extender{
+			RenderMode: RenderModeServer,
+			Compiler:   &compiler,
+		}),
+	)
+
+	src := []byte(unlines(
+		"```mermaid",
+		"graph",
+		"```",
+	))
+
+	t.Run("Convert", func(t *testing.T) {
+		got = nil
+
+		var buff bytes.Buffer
+		require.NoError(t, md.Convert(src, &buff, WithContext(ctx)))
+		require.NotNil(t, got)
+		assert.Equal(t, "hello", got.Value(testContextKey{}))
+	})
+
+	t.Run("ConvertContext", func(t *testing.T) {
+		got = nil
+
+		pc := parser.NewContext()
+		var buff bytes.Buffer
+		require.NoError(t, ConvertContext(ctx, md, src, &buff, parser.WithContext(pc)))
+		require.NotNil(t, got)
+		assert.Equal(t, "hello", got.Value(testContextKey{}))
+	})
+
+	t.Run("no context", func(t *testing.T) {
+		got = nil
+
+		var buff bytes.Buffer
+		require.NoError(t, md.Convert(src, &buff))
+		require.NotNil(t, got)
+		assert.Nil(t, got.Value(testContextKey{}))
+	})
+}
@@ -0,0 +1,94 @@
+package mermaid
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"math"
+)
+
+// _deterministicPrecision is the number of decimal places
+// numbers are rounded to in deterministic mode.
+const _deterministicPrecision = 3
+
+// deterministicSeed returns the seed for Mermaid's deterministic IDs
+// for the given diagram source.
+func deterministicSeed(source []byte) string {
+	sum := sha256.Sum256(source)
+	return hex.EncodeToString(sum[:8])
+}
+
+// normalizeSVG removes variance from compiled SVGs
+// so that the same diagram always produces the same bytes.
+//
+// Mermaid's layout may produce slightly different numbers
+// on different machines and between runs.
+// This rounds numbers in coordinates and sizes,
+// including the edge coordinates Mermaid records in data-points attributes.
+func normalizeSVG(doc *SVGDocument) error {
+	doc.Walk(func(e *SVGElement) {
+		for i, attr := range e.Attr {
+			name := attrName(attr.Name)
+			if name == "data-points" {
+				if v, ok := roundDataPoints(attr.Value, _deterministicPrecision); ok {
+					e.Attr[i].Value = v
+				}
+				continue
+			}
+
+			if _, ok := _svgGeometryAttrs[name]; ok {
+				e.Attr[i].Value = roundNumbers(attr.Value, _deterministicPrecision)
+			}
+		}
+	})
+	return nil
+}
+
+// roundDataPoints rounds numbers in a data-points attribute:
+// base64-encoded JSON holding a list of points.
+// It reports false if the value isn't in that format.
+func roundDataPoints(value string, precision int) (string, bool) {
+	data, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return "", false
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var points any
+	if err := dec.Decode(&points); err != nil {
+		return "", false
+	}
+
+	data, err = json.Marshal(roundJSONNumbers(points, math.Pow10(precision)))
+	if err != nil {
+		return "", false
+	}
+	return base64.StdEncoding.EncodeToString(data), true
+}
+
+func roundJSONNumbers(v any, scale float64) any {
+	switch v := v.(type) {
+	case json.Number:
+		f, err := v.Float64()
+		if err != nil {
+			return v
+		}
+		f = math.Round(f*scale) / scale
+		if f == 0 {
+			f = 0 // no -0
+		}
+		return json.Number(formatSVGNumber(f))
+	case []any:
+		for i, x := range v {
+			v[i] = roundJSONNumbers(x, scale)
+		}
+	case map[string]any:
+		for k, x := range v {
+			v[k] = roundJSONNumbers(x, scale)
+		}
+	}
+	return v
+}
@@ -0,0 +1,90 @@
+package mermaid
+
+import (
+	"encoding/base64"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNormalizeSVG(t *testing.T) {
+	t.Parallel()
+
+	points := func(json string) string {
+		return base64.StdEncoding.EncodeToString([]byte(json))
+	}
+
+	tests := []struct {
+		name string
+		give string
+		want string
+	}{
+		{
+			name: "geometry",
+			give: `<svg viewBox="-8 -8 85.43750762939453 174.00000190734863">` +
+				`<path d="M42.71875,33.5L42.71875,37.66666666666667"/>` +
+				`<rect x="-0.00001" width="12.3456" data-value="1.23456"/></svg>`,
+			want: `<svg viewBox="-8 -8 85.438 174">` +
+				`<path d="M42.719,33.5L42.719,37.667"/>` +
+				`<rect x="0" width="12.346" data-value="1.23456"/></svg>`,
+		},
+		{
+			name: "data points",
+			give: `<svg><path data-points="` + points(`[{"x":42.71875,"y":62},{"x":-0.0001,"y":87.33333333}]`) + `"/></svg>`,
+			want: `<svg><path data-points="` + points(`[{"x":42.719,"y":62},{"x":0,"y":87.333}]`) + `"/></svg>`,
+		},
+		{
+			name: "data points/invalid",
+			give: `<svg><path data-points="not base64"/><path data-points="` + points(`{`) + `"/></svg>`,
+			want: `<svg><path data-points="not base64"/><path data-points="` + points(`{`) + `"/></svg>`,
+		},
+		{
+			name: "text",
+			give: `<svg><text x="1.23456">3.14159</text></svg>`,
+			want: `<svg><text x="1.235">3.14159</text></svg>`,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := transformSVG(tt.give, []SVGTransformer{SVGTransformerFunc(normalizeSVG)})
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestDeterministicSeed(t *testing.T) {
+	t.Parallel()
+
+	a := deterministicSeed([]byte("graph TD; A-->B;"))
+	assert.Len(t, a, 16)
+	assert.Equal(t, a, deterministicSeed([]byte("graph TD; A-->B;")))
+	assert.NotEqual(t, a, deterministicSeed([]byte("graph TD; A-->C;")))
+}
+
+// TestNormalizeSVG_golden verifies that normalizing SVGs generated by Mermaid
+// is stable: normalizing them again doesn't change them.
+func TestNormalizeSVG_golden(t *testing.T) {
+	t.Parallel()
+
+	normalize := []SVGTransformer{SVGTransformerFunc(normalizeSVG)}
+	for i, svg := range goldenSVGs(t) {
+		i, svg := i, svg
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Parallel()
+
+			once, err := transformSVG(svg, normalize)
+			require.NoError(t, err)
+
+			twice, err := transformSVG(once, normalize)
+			require.NoError(t, err)
+			assert.Equal(t, once, twice)
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package mermaid
+
+// Diagnostic is a problem with a diagram
+// that didn't stop it from being rendered.
+type Diagnostic struct {
+	// Source is the raw Mermaid diagram source.
+	Source string
+
+	// ID is the ID of the diagram's container, if any.
+	ID string
+
+	// Message describes the problem.
+	Message string
+}
+
+func (d *Diagnostic) String() string {
+	if len(d.ID) > 0 {
+		return d.ID + ": " + d.Message
+	}
+	return d.Message
+}
+
+// DiagnosticHandler receives problems found with diagrams
+// while rendering a document.
+//
+// Use it to log problems or to fail builds in CI.
+type DiagnosticHandler interface {
+	HandleDiagnostic(d *Diagnostic)
+}
+
+// DiagnosticHandlerFunc is a [DiagnosticHandler] defined by a function.
+type DiagnosticHandlerFunc func(d *Diagnostic)
+
+var _ DiagnosticHandler = DiagnosticHandlerFunc(nil)
+
+// HandleDiagnostic calls the function.
+func (f DiagnosticHandlerFunc) HandleDiagnostic(d *Diagnostic) {
+	f(d)
+}
+
+// reportDiagnostic sends a Diagnostic for the given Block to h
+// if h is non-nil.
+func reportDiagnostic(h DiagnosticHandler, n *Block, src []byte, msg string) {
+	if h == nil {
+		return
+	}
+
+	id, _ := attributeString(n, _attrID)
+	h.HandleDiagnostic(&Diagnostic{
+		Source:  string(n.source(src)),
+		ID:      id,
+		Message: msg,
+	})
+}
@@ -0,0 +1,234 @@
+package mermaid
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"sync"
+
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/util"
+)
+
+// CompileError is a failure to compile a Mermaid diagram server-side.
+type CompileError struct {
+	// Source is the raw Mermaid diagram source.
+	Source string
+
+	// Err is the error returned by the Compiler,
+	// or by the SVG transformations applied to its output.
+	Err error
+
+	// collected holds the errors recorded by ErrorCollector
+	// for the conversion this error happened in.
+	collected *collectedErrors
+}
+
+func (e *CompileError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *CompileError) Unwrap() error {
+	return e.Err
+}
+
+// ErrorHandler decides what happens when [ServerRenderer]
+// fails to compile a diagram or to transform its SVG.
+//
+// Use one of the built-in handlers
+// [FailOnError], [RenderErrorBox], or [RenderSourceOnError],
+// or provide your own with [ErrorHandlerFunc].
+type ErrorHandler interface {
+	// HandleError is called when a diagram fails to compile.
+	//
+	// It may write HTML to w in place of the diagram,
+	// or return an error to stop rendering the document.
+	HandleError(w util.BufWriter, err *CompileError) error
+}
+
+// ErrorHandlerFunc is an [ErrorHandler] defined by a function.
+//
+// Use it to write custom HTML in place of diagrams that fail to compile.
+type ErrorHandlerFunc func(w util.BufWriter, err *CompileError) error
+
+var _ ErrorHandler = ErrorHandlerFunc(nil)
+
+// HandleError calls the function.
+func (f ErrorHandlerFunc) HandleError(w util.BufWriter, err *CompileError) error {
+	return f(w, err)
+}
+
+var (
+	// FailOnError is an [ErrorHandler] that stops rendering the document
+	// when a diagram fails to compile.
+	//
+	// This is the default behavior.
+	FailOnError ErrorHandler = ErrorHandlerFunc(failOnError)
+
+	// RenderErrorBox is an [ErrorHandler] that renders
+	// an inline error box with the error message
+	// in place of diagrams that fail to compile.
+	//
+	// The box is a <div class="mermaid-error" role="alert">.
+	RenderErrorBox ErrorHandler = ErrorHandlerFunc(renderErrorBox)
+
+	// RenderSourceOnError is an [ErrorHandler] that renders
+	// the source of diagrams that fail to compile as a code block.
+	RenderSourceOnError ErrorHandler = ErrorHandlerFunc(renderSourceOnError)
+)
+
+func failOnError(_ util.BufWriter, err *CompileError) error {
+	return fmt.Errorf("generate svg: %w", err)
+}
+
+func renderErrorBox(w util.BufWriter, err *CompileError) error {
+	_, _ = w.WriteString(`<div class="mermaid-error" role="alert">`)
+	_, _ = w.WriteString(`<p>Unable to render diagram:</p><pre>`)
+	template.HTMLEscape(w, []byte(err.Error()))
+	_, _ = w.WriteString(`</pre></div>`)
+	return nil
+}
+
+func renderSourceOnError(w util.BufWriter, err *CompileError) error {
+	_, _ = w.WriteString(`<pre><code class="language-mermaid">`)
+	template.HTMLEscape(w, []byte(err.Source))
+	_, _ = w.WriteString(`</code></pre>`)
+	return nil
+}
+
+// ErrorCollector is an [ErrorHandler] that records compilation errors
+// before handing them to another ErrorHandler.
+//
+// Use it to inspect errors after rendering documents
+// with a handler that does not stop rendering.
+//
+//	errs := &mermaid.ErrorCollector{Handler: mermaid.RenderErrorBox}
+//	md := goldmark.New(goldmark.WithExtensions(&mermaid.Extender{
+//		ErrorHandler: errs,
+//	}))
+//	if err := md.Convert(src, &buf); err != nil {
+//		// ...
+//	}
+//	if err := errs.Err(); err != nil {
+//		log.Printf("some diagrams failed to render: %v", err)
+//	}
+//
+// ErrorCollector is safe for concurrent use.
+// It accumulates errors across all documents it's used with
+// until it's Reset,
+// so Errors and Err mix together the errors of concurrent conversions.
+// Use [CollectedErrors] to get the errors of a single conversion.
+type ErrorCollector struct {
+	// Handler handles errors after they're recorded.
+	//
+	// Defaults to RenderErrorBox.
+	Handler ErrorHandler
+
+	mu   sync.Mutex
+	errs []*CompileError
+}
+
+var _ ErrorHandler = (*ErrorCollector)(nil)
+
+// HandleError records the error and passes it to Handler.
+func (c *ErrorCollector) HandleError(w util.BufWriter, err *CompileError) error {
+	c.mu.Lock()
+	c.errs = append(c.errs, err)
+	c.mu.Unlock()
+	if err.collected != nil {
+		err.collected.add(err)
+	}
+
+	h := c.Handler
+	if h == nil {
+		h = RenderErrorBox
+	}
+	return h.HandleError(w, err)
+}
+
+// Errors returns the errors recorded so far.
+func (c *ErrorCollector) Errors() []*CompileError {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	errs := make([]*CompileError, len(c.errs))
+	copy(errs, c.errs)
+	return errs
+}
+
+// Err returns all errors recorded so far joined together,
+// or nil if there were none.
+func (c *ErrorCollector) Err() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	errs := make([]error, len(c.errs))
+	for i, err := range c.errs {
+		errs[i] = err
+	}
+	return errors.Join(errs...)
+}
+
+// Reset discards all recorded errors.
+func (c *ErrorCollector) Reset() {
+	c.mu.Lock()
+	c.errs = nil
+	c.mu.Unlock()
+}
+
+var _collectedErrorsKey = parser.NewContextKey()
+
+// collectedErrors holds the errors recorded by ErrorCollector
+// during a single conversion.
+type collectedErrors struct {
+	mu   sync.Mutex
+	errs []*CompileError
+}
+
+// collectedErrorsFrom returns the collectedErrors for the conversion
+// using pc, creating it if necessary.
+func collectedErrorsFrom(pc parser.Context) *collectedErrors {
+	if pc == nil {
+		return nil
+	}
+	return pc.ComputeIfAbsent(_collectedErrorsKey, func() any {
+		return new(collectedErrors)
+	}).(*collectedErrors)
+}
+
+func (c *collectedErrors) add(err *CompileError) {
+	c.mu.Lock()
+	c.errs = append(c.errs, err)
+	c.mu.Unlock()
+}
+
+// CollectedErrors returns the errors recorded by an [ErrorCollector]
+// while converting the document parsed with pc.
+//
+// Unlike ErrorCollector.Errors, this only reports errors
+// from this one conversion, even if the ErrorCollector
+// is shared by concurrent conversions.
+//
+//	pc := parser.NewContext()
+//	if err := md.Convert(src, &buf, parser.WithContext(pc)); err != nil {
+//		// ...
+//	}
+//	for _, err := range mermaid.CollectedErrors(pc) {
+//		log.Printf("diagram failed to render: %v", err)
+//	}
+func CollectedErrors(pc parser.Context) []*CompileError {
+	if pc == nil {
+		return nil
+	}
+	c, _ := pc.Get(_collectedErrorsKey).(*collectedErrors)
+	if c == nil {
+		return nil
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	errs := make([]*CompileError, len(c.errs))
+	copy(errs, c.errs)
+	return errs
+}
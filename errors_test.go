@@ -0,0 +1,262 @@
+package mermaid
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/util"
+)
+
+func TestServerRenderer_ErrorHandler(t *testing.T) {
+	t.Parallel()
+
+	compiler := compilerStub{
+		CompileF: func(_ context.Context, req *CompileRequest) (*CompileResponse, error) {
+			if strings.HasPrefix(req.Source, "bad") {
+				return nil, errors.New("syntax <error>")
+			}
+			return &CompileResponse{
+				SVG: "<svg>" + strings.TrimSpace(req.Source) + "</svg>",
+			}, nil
+		},
+	}
+
+	src := []byte(unlines(
+		"```mermaid",
+		"good",
+		"```",
+		"",
+		"```mermaid",
+		"bad --> x",
+		"```",
+	))
+
+	tests := []struct {
+		desc    string
+		handler ErrorHandler
+		want    string
+		wantErr string
+	}{
+		{
+			desc:    "default",
+			wantErr: "generate svg: syntax <error>",
+		},
+		{
+			desc:    "fail",
+			handler: FailOnError,
+			wantErr: "generate svg: syntax <error>",
+		},
+		{
+			desc:    "error box",
+			handler: RenderErrorBox,
+			want: `<div class="mermaid mermaid-rendered" data-processed="true"><svg>good</svg></div>` +
+				`<div class="mermaid-error" role="alert"><p>Unable to render diagram:</p>` +
+				`<pre>syntax &lt;error&gt;</pre></div>`,
+		},
+		{
+			desc:    "source",
+			handler: RenderSourceOnError,
+			want: `<div class="mermaid mermaid-rendered" data-processed="true"><svg>good</svg></div>` +
+				`<pre><code class="language-mermaid">bad --&gt; x` + "\n" + `</code></pre>`,
+		},
+		{
+			desc: "func",
+			handler: ErrorHandlerFunc(func(w util.BufWriter, err *CompileError) error {
+				_, _ = w.WriteString("<p>oops</p>")
+				return nil
+			}),
+			want: `<div class="mermaid mermaid-rendered" data-processed="true"><svg>good</svg></div>` +
+				`<p>oops</p>`,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.desc, func(t *testing.T) {
+			t.Parallel()
+
+			md := goldmark.New(
+				goldmark.WithExtensions(&Extender{
+					RenderMode:   RenderModeServer,
+					Compiler:     &compiler,
+					ErrorHandler: tt.handler,
+				}),
+			)
+
+			var buff bytes.Buffer
+			err := md.Convert(src, &buff)
+			if tt.wantErr != "" {
+				assert.ErrorContains(t, err, tt.wantErr)
+
+				var compileErr *CompileError
+				if assert.ErrorAs(t, err, &compileErr) {
+					assert.Equal(t, "bad --> x\n", compileErr.Source)
+				}
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, buff.String())
+		})
+	}
+}
+
+func TestServerRenderer_ErrorHandler_transformSVG(t *testing.T) {
+	t.Parallel()
+
+	compiler := compilerStub{
+		CompileF: func(context.Context, *CompileRequest) (*CompileResponse, error) {
+			return &CompileResponse{SVG: "not an svg"}, nil
+		},
+	}
+
+	src := []byte(unlines(
+		"```mermaid",
+		"graph",
+		"```",
+	))
+
+	t.Run("error box", func(t *testing.T) {
+		t.Parallel()
+
+		md := goldmark.New(
+			goldmark.WithExtensions(&Extender{
+				RenderMode:   RenderModeServer,
+				Compiler:     &compiler,
+				Accessible:   true,
+				ErrorHandler: RenderErrorBox,
+			}),
+		)
+
+		var buff bytes.Buffer
+		require.NoError(t, md.Convert(src, &buff))
+		assert.Equal(t,
+			`<div class="mermaid-error" role="alert"><p>Unable to render diagram:</p>`+
+				`<pre>transform svg: no &lt;svg&gt; element found</pre></div>`,
+			buff.String())
+	})
+
+	t.Run("default", func(t *testing.T) {
+		t.Parallel()
+
+		md := goldmark.New(
+			goldmark.WithExtensions(&Extender{
+				RenderMode: RenderModeServer,
+				Compiler:   &compiler,
+				Accessible: true,
+			}),
+		)
+
+		var buff bytes.Buffer
+		err := md.Convert(src, &buff)
+		assert.ErrorContains(t, err, "generate svg: transform svg: no <svg> element found")
+
+		var compileErr *CompileError
+		if assert.ErrorAs(t, err, &compileErr) {
+			assert.Equal(t, "graph\n", compileErr.Source)
+		}
+	})
+}
+
+func TestErrorCollector(t *testing.T) {
+	t.Parallel()
+
+	compiler := compilerStub{
+		CompileF: func(_ context.Context, req *CompileRequest) (*CompileResponse, error) {
+			return nil, errors.New("failed: " + strings.TrimSpace(req.Source))
+		},
+	}
+
+	var errs ErrorCollector
+	md := goldmark.New(
+		goldmark.WithExtensions(&Extender{
+			RenderMode:   RenderModeServer,
+			Compiler:     &compiler,
+			ErrorHandler: &errs,
+		}),
+	)
+
+	var buff bytes.Buffer
+	require.NoError(t, md.Convert([]byte(unlines(
+		"```mermaid",
+		"foo",
+		"```",
+		"",
+		"```mermaid",
+		"bar",
+		"```",
+	)), &buff))
+	assert.Equal(t, 2, strings.Count(buff.String(), `<div class="mermaid-error"`),
+		"should use RenderErrorBox by default")
+
+	got := errs.Errors()
+	require.Len(t, got, 2)
+	assert.Equal(t, "foo\n", got[0].Source)
+	assert.Equal(t, "bar\n", got[1].Source)
+	assert.EqualError(t, errs.Err(), "failed: foo\nfailed: bar")
+
+	errs.Reset()
+	assert.Empty(t, errs.Errors())
+	assert.NoError(t, errs.Err())
+}
+
+func TestCollectedErrors(t *testing.T) {
+	t.Parallel()
+
+	compiler := compilerStub{
+		CompileF: func(_ context.Context, req *CompileRequest) (*CompileResponse, error) {
+			return nil, errors.New("failed: " + strings.TrimSpace(req.Source))
+		},
+	}
+
+	errs := new(ErrorCollector)
+	md := goldmark.New(
+		goldmark.WithExtensions(&Extender{
+			RenderMode:   RenderModeServer,
+			Compiler:     &compiler,
+			ErrorHandler: errs,
+		}),
+	)
+
+	sources := []string{"foo", "bar", "baz", "qux"}
+	got := make([][]*CompileError, len(sources))
+	var wg sync.WaitGroup
+	for i, source := range sources {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			pc := parser.NewContext()
+			var buff bytes.Buffer
+			assert.NoError(t, md.Convert([]byte(unlines(
+				"```mermaid",
+				source,
+				"```",
+			)), &buff, parser.WithContext(pc)))
+			got[i] = CollectedErrors(pc)
+		}()
+	}
+	wg.Wait()
+
+	for i, source := range sources {
+		if assert.Len(t, got[i], 1, "source %q", source) {
+			assert.Equal(t, source+"\n", got[i][0].Source)
+		}
+	}
+	assert.Len(t, errs.Errors(), len(sources))
+
+	t.Run("no errors", func(t *testing.T) {
+		t.Parallel()
+
+		assert.Empty(t, CollectedErrors(parser.NewContext()))
+		assert.Empty(t, CollectedErrors(nil))
+	})
+}
@@ -3,6 +3,7 @@ package mermaid
 import (
 	"fmt"
 	"os/exec"
+	"time"
 
 	"github.com/yuin/goldmark"
 	"github.com/yuin/goldmark/parser"
@@ -39,6 +40,8 @@ type Extender struct {
 	// for client-side rendering.
 	//
 	// Ignored if NoScript is true or if we're rendering diagrams server-side.
+	// In hybrid mode, this is used only if a diagram falls back
+	// to client-side rendering.
 	//
 	// Defaults to the latest version available on cdn.jsdelivr.net.
 	MermaidURL string
@@ -49,6 +52,132 @@ type Extender struct {
 	// and "div" for server-side rendering.
 	ContainerTag string
 
+	// Class attribute of the container element for diagrams.
+	//
+	// Defaults to "mermaid".
+	// See ClientRenderer.ContainerClass and ServerRenderer.ContainerClass
+	// for details.
+	ContainerClass string
+
+	// Additional attributes for the container element for diagrams.
+	// For example, data-* attributes.
+	ContainerAttributes map[string]string
+
+	// If true, diagrams without an explicit ID
+	// are assigned one based on a hash of their contents.
+	//
+	// IDs may be specified explicitly on the code block:
+	//
+	//	```mermaid {#my-diagram}
+	GenerateIDs bool
+
+	// ErrorHandler decides what to do
+	// when a diagram fails to compile server-side.
+	//
+	// Defaults to FailOnError, which stops rendering the document.
+	// In hybrid mode, this is used only for diagrams that exceed a limit.
+	ErrorHandler ErrorHandler
+
+	// Concurrency is the maximum number of diagrams
+	// compiled at the same time when rendering server-side.
+	//
+	// If greater than one, all diagrams in a document
+	// are compiled concurrently before rendering.
+	// See ServerRenderer.Concurrency for details.
+	Concurrency int
+
+	// Timeout is the maximum time allowed to compile a single diagram
+	// when rendering server-side.
+	// See ServerRenderer.Timeout for details.
+	Timeout time.Duration
+
+	// MaxSourceSize is the maximum size of a diagram's source in bytes
+	// when rendering server-side.
+	// See ServerRenderer.MaxSourceSize for details.
+	MaxSourceSize int
+
+	// MaxDiagrams is the maximum number of diagrams
+	// compiled in a single document when rendering server-side.
+	// See ServerRenderer.MaxDiagrams for details.
+	MaxDiagrams int
+
+	// MaxEdges is the maximum number of edges in a diagram
+	// when rendering server-side.
+	// See ServerRenderer.MaxEdges for details.
+	MaxEdges int
+
+	// Assets, if set, writes diagrams compiled server-side
+	// as separate files referenced with <img> tags.
+	// See ServerRenderer.Assets for details.
+	Assets AssetWriter
+
+	// AssetURLPrefix is the URL prefix for files written to Assets.
+	//
+	// Defaults to "/assets/mermaid/".
+	AssetURLPrefix string
+
+	// Format is the image format to compile diagrams into
+	// when rendering server-side.
+	// See ServerRenderer.Format for details.
+	//
+	// Defaults to FormatSVG.
+	Format Format
+
+	// Scale is the ratio of image pixels to diagram pixels
+	// for FormatPNG.
+	//
+	// Defaults to 1.
+	Scale float64
+
+	// MermaidConfig holds Mermaid configuration
+	// for diagrams rendered server-side.
+	// See ServerRenderer.MermaidConfig for details.
+	MermaidConfig map[string]any
+
+	// Background is the CSS background color
+	// of diagrams rendered server-side.
+	// See ServerRenderer.Background for details.
+	Background string
+
+	// Deterministic makes compiling the same diagram server-side
+	// always produce the same output.
+	// See ServerRenderer.Deterministic for details.
+	Deterministic bool
+
+	// SharedStyles moves the theme stylesheet out of each diagram
+	// compiled server-side into one stylesheet shared by the document.
+	// See ServerRenderer.SharedStyles for details.
+	SharedStyles bool
+
+	// Sizing controls the dimensions of diagrams compiled server-side.
+	// See ServerRenderer.Sizing for details.
+	Sizing Sizing
+
+	// UniqueIDs rewrites the IDs inside diagrams compiled server-side
+	// so that they don't collide with other diagrams on the page.
+	// See ServerRenderer.UniqueIDs for details.
+	UniqueIDs bool
+
+	// Accessible makes diagrams compiled server-side
+	// accessible to screen readers.
+	// See ServerRenderer.Accessible for details.
+	Accessible bool
+
+	// Diagnostics, if set, receives problems found with diagrams
+	// rendered server-side.
+	// See ServerRenderer.Diagnostics for details.
+	Diagnostics DiagnosticHandler
+
+	// SVGTransformers modify diagrams compiled server-side
+	// before they're written to the document.
+	// See ServerRenderer.SVGTransformers for details.
+	SVGTransformers []SVGTransformer
+
+	// Sanitize removes anything that could run scripts
+	// or load external resources from diagrams compiled server-side.
+	// See ServerRenderer.Sanitize for details.
+	Sanitize bool
+
 	// If true, don't add a <script> including Mermaid to the end of the
 	// page even if rendering diagrams client-side.
 	//
@@ -60,8 +189,60 @@ type Extender struct {
 	//
 	// Values include "dark", "default", "forest", and "neutral".
 	// See MermaidJS documentation for a full list.
+	//
+	// This applies to diagrams rendered client-side and server-side,
+	// including with a Compiler shared between Extenders.
 	Theme string
 
+	// If true, diagrams that fail to render client-side
+	// are replaced with an error panel describing the failure.
+	//
+	// Ignored if we're rendering diagrams server-side.
+	// In hybrid mode, this applies to diagrams
+	// that fall back to client-side rendering.
+	ShowErrors bool
+
+	// ErrorTemplate is the HTML for the error panel used by ShowErrors.
+	//
+	// See ClientRenderer.ErrorTemplate for details.
+	ErrorTemplate string
+
+	// If true, rendered diagrams are wrapped in a viewport
+	// that supports panning and zooming,
+	// with buttons to zoom and to view the diagram full screen.
+	//
+	// This works with both, client-side and server-side rendering.
+	// The supporting script and stylesheet are added to the end of the page
+	// unless NoScript is set.
+	PanZoom bool
+
+	// If true, each diagram gets a toolbar with buttons
+	// to view or copy its source, and to download it as an SVG or PNG.
+	//
+	// This works with both, client-side and server-side rendering.
+	// The supporting script and stylesheet are added to the end of the page
+	// unless NoScript is set.
+	Toolbar bool
+
+	// Callbacks are JavaScript functions that diagrams can invoke
+	// with Mermaid's click interaction.
+	//
+	// Ignored if we're rendering diagrams server-side.
+	// See ClientRenderer.Callbacks for details.
+	Callbacks []ClickCallback
+
+	// SecurityLevel is the Mermaid security level for diagrams
+	// rendered client-side.
+	//
+	// Defaults to "loose" if Callbacks are specified.
+	SecurityLevel string
+
+	// NoScriptFallback specifies what to render inside a <noscript> element
+	// after each diagram rendered client-side.
+	//
+	// See ClientRenderer.NoScriptFallback for details.
+	NoScriptFallback NoScriptFallback
+
 	execLookPath func(string) (string, error) // == exec.LookPath
 }
 
@@ -74,8 +255,11 @@ func (e *Extender) Extend(md goldmark.Markdown) {
 		parser.WithASTTransformers(
 			util.Prioritized(&Transformer{
 				// If rendering server-side,
-				// don't generate <script> tags.
-				NoScript: e.NoScript || mode == RenderModeServer,
+				// don't generate <script> tags
+				// unless we need them for pan and zoom or the toolbar.
+				NoScript:     e.NoScript || (mode == RenderModeServer && !e.PanZoom && !e.Toolbar),
+				SharedStyles: e.SharedStyles && mode != RenderModeClient,
+				GenerateIDs:  e.GenerateIDs,
 			}, 100),
 		),
 	)
@@ -100,21 +284,68 @@ func (e *Extender) renderer() (RenderMode, renderer.NodeRenderer) {
 
 	switch mode {
 	case RenderModeClient:
-		return RenderModeClient, &ClientRenderer{
-			MermaidURL:   e.MermaidURL,
-			ContainerTag: e.ContainerTag,
-			Theme:        e.Theme,
-		}
+		return RenderModeClient, e.clientRenderer()
 	case RenderModeServer:
-		return RenderModeServer, &ServerRenderer{
-			Compiler:     compiler,
-			ContainerTag: e.ContainerTag,
-		}
+		return RenderModeServer, e.serverRenderer(compiler)
+	case RenderModeHybrid:
+		r := e.serverRenderer(compiler)
+		r.Fallback = e.clientRenderer()
+		return RenderModeHybrid, r
 	default:
 		panic(fmt.Sprintf("unrecognized render mode: %v", mode))
 	}
 }
 
+func (e *Extender) serverRenderer(compiler Compiler) *ServerRenderer {
+	return &ServerRenderer{
+		Compiler:            compiler,
+		ContainerTag:        e.ContainerTag,
+		ContainerClass:      e.ContainerClass,
+		ContainerAttributes: e.ContainerAttributes,
+		ErrorHandler:        e.ErrorHandler,
+		Concurrency:         e.Concurrency,
+		Timeout:             e.Timeout,
+		MaxSourceSize:       e.MaxSourceSize,
+		MaxDiagrams:         e.MaxDiagrams,
+		MaxEdges:            e.MaxEdges,
+		Assets:              e.Assets,
+		AssetURLPrefix:      e.AssetURLPrefix,
+		Format:              e.Format,
+		Scale:               e.Scale,
+		Theme:               e.Theme,
+		MermaidConfig:       e.MermaidConfig,
+		Background:          e.Background,
+		Deterministic:       e.Deterministic,
+		SharedStyles:        e.SharedStyles,
+		Sizing:              e.Sizing,
+		UniqueIDs:           e.UniqueIDs,
+		Accessible:          e.Accessible,
+		Diagnostics:         e.Diagnostics,
+		SVGTransformers:     e.SVGTransformers,
+		Sanitize:            e.Sanitize,
+		PanZoom:             e.PanZoom,
+		Toolbar:             e.Toolbar,
+	}
+}
+
+func (e *Extender) clientRenderer() *ClientRenderer {
+	return &ClientRenderer{
+		MermaidURL:          e.MermaidURL,
+		ContainerTag:        e.ContainerTag,
+		ContainerClass:      e.ContainerClass,
+		ContainerAttributes: e.ContainerAttributes,
+		Theme:               e.Theme,
+		ShowErrors:          e.ShowErrors,
+		ErrorTemplate:       e.ErrorTemplate,
+		PanZoom:             e.PanZoom,
+		Toolbar:             e.Toolbar,
+		Callbacks:           e.Callbacks,
+		SecurityLevel:       e.SecurityLevel,
+
+		NoScriptFallback: e.NoScriptFallback,
+	}
+}
+
 // compiler returns the Compiler to use for server-side rendering
 // only if server-side rendering should be used.
 //
@@ -47,3 +47,24 @@ func TestExtender_rendererAuto(t *testing.T) {
 		})
 	})
 }
+
+func TestExtender_rendererHybrid(t *testing.T) {
+	t.Parallel()
+
+	ext := Extender{
+		RenderMode: RenderModeHybrid,
+		MermaidURL: "mermaid.js",
+		execLookPath: func(string) (string, error) {
+			return "/path/to/mmdc", nil
+		},
+	}
+
+	mode, r := ext.renderer()
+	assert.Equal(t, RenderModeHybrid, mode)
+	if assert.IsType(t, new(ServerRenderer), r) {
+		fallback := r.(*ServerRenderer).Fallback
+		if assert.NotNil(t, fallback) {
+			assert.Equal(t, "mermaid.js", fallback.MermaidURL)
+		}
+	}
+}
@@ -0,0 +1,25 @@
+package mermaid
+
+// Format is the image format of a compiled diagram.
+type Format int
+
+//go:generate stringer -type Format -trimprefix Format
+
+const (
+	// FormatSVG compiles diagrams into SVG images.
+	// The result is in CompileResponse.SVG.
+	FormatSVG Format = iota
+
+	// FormatPNG compiles diagrams into PNG images.
+	// The result is in CompileResponse.Data.
+	FormatPNG
+
+	// FormatPDF compiles diagrams into PDF documents
+	// with a single page sized to fit the diagram.
+	// The result is in CompileResponse.Data.
+	//
+	// PDF diagrams can't be rendered into HTML,
+	// so this is supported only by compilers.
+	// See also CompilePDF.
+	FormatPDF
+)
@@ -0,0 +1,79 @@
+package mermaid
+
+import (
+	"fmt"
+	"time"
+)
+
+// Limit identifies a resource limit on rendering diagrams.
+// See [LimitError].
+type Limit int
+
+//go:generate stringer -type Limit -trimprefix Limit
+
+const (
+	// LimitSourceSize is the maximum size of a diagram's source.
+	// See ServerRenderer.MaxSourceSize.
+	LimitSourceSize Limit = iota + 1
+
+	// LimitDiagrams is the maximum number of diagrams in a document.
+	// See ServerRenderer.MaxDiagrams.
+	LimitDiagrams
+
+	// LimitEdges is the maximum number of edges in a diagram.
+	// See ServerRenderer.MaxEdges.
+	LimitEdges
+
+	// LimitTimeout is the maximum time taken to compile a diagram.
+	// See ServerRenderer.Timeout.
+	LimitTimeout
+)
+
+// LimitError indicates that a diagram exceeded a resource limit
+// set on [ServerRenderer].
+//
+// These are reported to the ErrorHandler wrapped in a [CompileError].
+// Use errors.As to tell them apart from other failures.
+//
+//	var limitErr *mermaid.LimitError
+//	if errors.As(err, &limitErr) {
+//		// ...
+//	}
+type LimitError struct {
+	// Limit is the limit that was exceeded.
+	Limit Limit
+
+	// Max is the value of the limit:
+	// a number of bytes, diagrams, or edges,
+	// or a time.Duration for LimitTimeout.
+	Max int64
+
+	// Err is the underlying error, if any.
+	// For example, context.DeadlineExceeded for LimitTimeout.
+	Err error
+}
+
+func (e *LimitError) Error() string {
+	var msg string
+	switch e.Limit {
+	case LimitSourceSize:
+		msg = fmt.Sprintf("diagram source exceeds %d bytes", e.Max)
+	case LimitDiagrams:
+		msg = fmt.Sprintf("document exceeds %d diagrams", e.Max)
+	case LimitEdges:
+		msg = fmt.Sprintf("diagram exceeds %d edges", e.Max)
+	case LimitTimeout:
+		msg = fmt.Sprintf("diagram took longer than %v to compile", time.Duration(e.Max))
+	default:
+		msg = fmt.Sprintf("diagram exceeds %v limit of %d", e.Limit, e.Max)
+	}
+
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+func (e *LimitError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,60 @@
+package mermaid
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLimitError(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		give *LimitError
+		want string
+	}{
+		{
+			name: "source size",
+			give: &LimitError{Limit: LimitSourceSize, Max: 100},
+			want: "diagram source exceeds 100 bytes",
+		},
+		{
+			name: "diagrams",
+			give: &LimitError{Limit: LimitDiagrams, Max: 5},
+			want: "document exceeds 5 diagrams",
+		},
+		{
+			name: "edges",
+			give: &LimitError{Limit: LimitEdges, Max: 50, Err: errors.New("great sadness")},
+			want: "diagram exceeds 50 edges: great sadness",
+		},
+		{
+			name: "timeout",
+			give: &LimitError{
+				Limit: LimitTimeout,
+				Max:   int64(2 * time.Second),
+				Err:   context.DeadlineExceeded,
+			},
+			want: "diagram took longer than 2s to compile: context deadline exceeded",
+		},
+		{
+			name: "unknown",
+			give: &LimitError{Limit: Limit(42), Max: 1},
+			want: "diagram exceeds Limit(42) limit of 1",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.EqualError(t, tt.give, tt.want)
+			assert.Equal(t, tt.give.Err, errors.Unwrap(tt.give))
+		})
+	}
+}
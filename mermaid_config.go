@@ -0,0 +1,45 @@
+package mermaid
+
+// MermaidConfig returns the Mermaid configuration for the request:
+// Config with the other fields of the request
+// that map to Mermaid settings applied on top.
+// It returns nil if the request doesn't need any configuration.
+//
+// Compilers pass this to Mermaid,
+// for example, with mermaid.initialize or mmdc's --configFile.
+// The returned map may be modified without affecting the request.
+func (r *CompileRequest) MermaidConfig() map[string]any {
+	cfg := make(map[string]any, len(r.Config)+5)
+	for k, v := range r.Config {
+		cfg[k] = v
+	}
+
+	if len(r.Theme) > 0 {
+		cfg["theme"] = r.Theme
+	}
+	if r.MaxTextSize > 0 {
+		cfg["maxTextSize"] = r.MaxTextSize
+	}
+	if r.MaxEdges > 0 {
+		cfg["maxEdges"] = r.MaxEdges
+	}
+	if seed := r.DeterministicIDSeed; len(seed) > 0 {
+		cfg["deterministicIds"] = true
+		cfg["deterministicIDSeed"] = seed
+
+		// Today's date changes daily.
+		gantt := make(map[string]any)
+		if g, ok := cfg["gantt"].(map[string]any); ok {
+			for k, v := range g {
+				gantt[k] = v
+			}
+		}
+		gantt["todayMarker"] = "off"
+		cfg["gantt"] = gantt
+	}
+
+	if len(cfg) == 0 {
+		return nil
+	}
+	return cfg
+}
@@ -0,0 +1,98 @@
+package mermaid
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompileRequest_MermaidConfig(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		give CompileRequest
+		want map[string]any
+	}{
+		{
+			name: "empty",
+			give: CompileRequest{Source: "A -> B"},
+		},
+		{
+			name: "config",
+			give: CompileRequest{
+				Config: map[string]any{"fontSize": 12},
+			},
+			want: map[string]any{"fontSize": 12},
+		},
+		{
+			name: "fields",
+			give: CompileRequest{
+				Theme:       "dark",
+				MaxTextSize: 100,
+				MaxEdges:    10,
+			},
+			want: map[string]any{
+				"theme":       "dark",
+				"maxTextSize": 100,
+				"maxEdges":    10,
+			},
+		},
+		{
+			name: "fields override config",
+			give: CompileRequest{
+				Theme: "dark",
+				Config: map[string]any{
+					"theme":    "forest",
+					"fontSize": 12,
+				},
+			},
+			want: map[string]any{
+				"theme":    "dark",
+				"fontSize": 12,
+			},
+		},
+		{
+			name: "deterministic",
+			give: CompileRequest{
+				DeterministicIDSeed: "seed",
+				Config: map[string]any{
+					"gantt": map[string]any{"barHeight": 20},
+				},
+			},
+			want: map[string]any{
+				"deterministicIds":    true,
+				"deterministicIDSeed": "seed",
+				"gantt": map[string]any{
+					"barHeight":   20,
+					"todayMarker": "off",
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.want, tt.give.MermaidConfig())
+		})
+	}
+}
+
+func TestCompileRequest_MermaidConfig_copies(t *testing.T) {
+	t.Parallel()
+
+	gantt := map[string]any{"barHeight": 20}
+	req := CompileRequest{
+		Config:              map[string]any{"gantt": gantt},
+		DeterministicIDSeed: "seed",
+	}
+
+	cfg := req.MermaidConfig()
+	cfg["theme"] = "dark"
+
+	assert.Equal(t, map[string]any{"gantt": gantt}, req.Config)
+	assert.Equal(t, map[string]any{"barHeight": 20}, gantt)
+}
@@ -2,13 +2,18 @@ package mermaidcdp
 
 import (
 	"context"
+	"crypto/sha256"
 	_ "embed" // for go:embed
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"math"
 	"runtime"
 	"strings"
 	"sync"
+	"time"
 
+	"github.com/chromedp/cdproto/page"
 	cdruntime "github.com/chromedp/cdproto/runtime"
 	"github.com/chromedp/chromedp"
 	"go.abhg.dev/goldmark/mermaid"
@@ -31,6 +36,8 @@ type Config struct {
 	//
 	// Values include "dark", "default", "forest", and "neutral".
 	// See MermaidJS documentation for a full list.
+	//
+	// CompileRequest.Theme takes precedence over this.
 	Theme string
 
 	// NoSandbox disables the sandbox for the headless browser.
@@ -46,6 +53,57 @@ type mermaidInitializeConfig struct {
 	StartOnLoad bool   `json:"startOnLoad"`
 }
 
+// Options for rendering a single diagram
+// passed to renderSVG and renderElement.
+type renderOptions struct {
+	ID         string `json:"id,omitempty"`
+	Width      int    `json:"width,omitempty"`
+	Background string `json:"background,omitempty"`
+
+	// Mermaid configuration layered on top of mermaidInitializeConfig.
+	Config map[string]any `json:"config,omitempty"`
+}
+
+// Result of renderSVG and renderElement.
+type renderResult struct {
+	// ID of the element holding the diagram.
+	// Set only by renderElement.
+	ID string `json:"id"`
+
+	// Set only by renderSVG.
+	SVG string `json:"svg"`
+
+	Width       float64  `json:"width"`
+	Height      float64  `json:"height"`
+	ViewBox     string   `json:"viewBox"`
+	DiagramType string   `json:"diagramType"`
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Warnings    []string `json:"warnings"`
+}
+
+func (r *renderResult) response() *mermaid.CompileResponse {
+	return &mermaid.CompileResponse{
+		SVG:         r.SVG,
+		Width:       r.Width,
+		Height:      r.Height,
+		ViewBox:     r.ViewBox,
+		DiagramType: r.DiagramType,
+		Title:       r.Title,
+		Description: r.Description,
+		Warnings:    r.Warnings,
+	}
+}
+
+func newRenderOptions(req *mermaid.CompileRequest) *renderOptions {
+	return &renderOptions{
+		ID:         req.ID,
+		Width:      req.Width,
+		Background: req.Background,
+		Config:     req.MermaidConfig(),
+	}
+}
+
 // Compiler compiles Mermaid diagrams into SVGs.
 type Compiler struct {
 	mu sync.RWMutex // guards ctx
@@ -56,9 +114,30 @@ type Compiler struct {
 	//
 	// ctx is the context scoped to the headless browser.
 	ctx context.Context
+
+	// cacheKey identifies the configuration of this compiler.
+	cacheKey string
+
+	// printMu ensures that only one diagram is printed at a time
+	// because printing applies to the whole page.
+	printMu sync.Mutex
+
+	// viewportMu ensures that diagrams that need a specific viewport size
+	// are compiled alone because the viewport applies to the whole page.
+	// Other diagrams share it.
+	viewportMu sync.RWMutex
 }
 
-var _ mermaid.Compiler = (*Compiler)(nil)
+// Viewport size used for requests that specify only one dimension.
+const (
+	_defaultViewportWidth  = 800
+	_defaultViewportHeight = 600
+)
+
+var (
+	_ mermaid.Compiler   = (*Compiler)(nil)
+	_ mermaid.CacheKeyer = (*Compiler)(nil)
+)
 
 // New builds a new Compiler with the provided configuration.
 //
@@ -112,7 +191,7 @@ func New(cfg *Config) (_ *Compiler, err error) {
 		StartOnLoad: false,
 	}
 	var init strings.Builder
-	init.WriteString("mermaid.initialize(")
+	init.WriteString("initialize(")
 	if err := json.NewEncoder(&init).Encode(initConfig); err != nil {
 		return nil, fmt.Errorf("encode mermaid.initialize config: %w", err)
 	}
@@ -123,7 +202,10 @@ func New(cfg *Config) (_ *Compiler, err error) {
 		return nil, fmt.Errorf("initialize mermaid: %w", err)
 	}
 
-	c := &Compiler{ctx: ctx}
+	c := &Compiler{
+		ctx:      ctx,
+		cacheKey: cacheKey(cfg),
+	}
 	runtime.SetFinalizer(c, func(c *Compiler) {
 		// If the engine is garbage collected and not closed, close it.
 		_ = c.Close()
@@ -131,40 +213,249 @@ func New(cfg *Config) (_ *Compiler, err error) {
 	return c, nil
 }
 
-// Compile renders a Mermaid diagram into an SVG.
+// Compile renders a Mermaid diagram into an image.
+// FormatSVG, FormatPNG, and FormatPDF are supported.
 // The context controls how long the rendering is allowed to take.
 //
+// If the request sets Width or Height,
+// the page viewport is resized to match while the diagram is compiled.
+// Such requests are compiled one at a time.
+//
+// The response includes metadata about the diagram
+// and warnings that Mermaid logged while compiling it.
+//
 // Panics if the Compiler has already been closed.
 func (c *Compiler) Compile(ctx context.Context, req *mermaid.CompileRequest) (*mermaid.CompileResponse, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.ctx == nil {
+		panic("Compiler is closed")
+	}
+	ctx, cancel := mergeCtxLifetime(c.ctx, ctx)
+	defer cancel()
+
+	if req.Width > 0 || req.Height > 0 {
+		c.viewportMu.Lock()
+		defer c.viewportMu.Unlock()
+
+		reset, err := c.emulateViewport(ctx, req.Width, req.Height)
+		if err != nil {
+			return nil, err
+		}
+		defer reset()
+	} else {
+		c.viewportMu.RLock()
+		defer c.viewportMu.RUnlock()
+	}
+
+	var (
+		res   *mermaid.CompileResponse
+		err   error
+		start = time.Now()
+	)
+	switch req.Format {
+	case mermaid.FormatSVG:
+		res, err = c.compileSVG(ctx, req)
+	case mermaid.FormatPNG:
+		res, err = c.compilePNG(ctx, req)
+	case mermaid.FormatPDF:
+		res, err = c.compilePDF(ctx, req)
+	default:
+		return nil, fmt.Errorf("unsupported format %v", req.Format)
+	}
+	if err != nil {
+		return nil, err
+	}
+	res.Duration = time.Since(start)
+	return res, nil
+}
+
+func (c *Compiler) compileSVG(ctx context.Context, req *mermaid.CompileRequest) (*mermaid.CompileResponse, error) {
+	script, err := callScript("renderSVG", req.Source, newRenderOptions(req))
+	if err != nil {
+		return nil, err
+	}
+
+	// TODO: Can we use chromedp.CallFunctionOn instead?
+	var result renderResult
+	if err := chromedp.Run(ctx, evaluate(script, &result)); err != nil {
+		return nil, err
+	}
+	return result.response(), nil
+}
+
+func (c *Compiler) compilePNG(ctx context.Context, req *mermaid.CompileRequest) (*mermaid.CompileResponse, error) {
+	scale := req.Scale
+	if scale <= 0 {
+		scale = 1
+	}
+
+	var data []byte
+	result, err := c.withElement(ctx, req, func(id string) error {
+		err := chromedp.Run(ctx,
+			chromedp.ScreenshotScale("#"+id+" > svg", scale, &data, chromedp.ByQuery),
+		)
+		if err != nil {
+			return fmt.Errorf("screenshot: %w", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res := result.response()
+	res.Data = data
+	return res, nil
+}
+
+// Pixels per inch in CSS.
+const _cssPixelsPerInch = 96
+
+func (c *Compiler) compilePDF(ctx context.Context, req *mermaid.CompileRequest) (*mermaid.CompileResponse, error) {
+	c.printMu.Lock()
+	defer c.printMu.Unlock()
+
+	var data []byte
+	result, err := c.withElement(ctx, req, func(id string) error {
+		script, err := callScript("preparePrint", id)
+		if err != nil {
+			return err
+		}
+
+		var size [2]float64
+		if err := chromedp.Run(ctx, evaluate(script, &size)); err != nil {
+			return fmt.Errorf("prepare page: %w", err)
+		}
+
+		// Round up to avoid spilling onto a second page.
+		width := math.Ceil(size[0]) / _cssPixelsPerInch
+		height := math.Ceil(size[1]) / _cssPixelsPerInch
+		return chromedp.Run(ctx, chromedp.ActionFunc(func(ctx context.Context) (err error) {
+			data, _, err = page.PrintToPDF().
+				WithPrintBackground(true).
+				WithPaperWidth(width).
+				WithPaperHeight(height).
+				WithMarginTop(0).
+				WithMarginBottom(0).
+				WithMarginLeft(0).
+				WithMarginRight(0).
+				WithPageRanges("1").
+				Do(ctx)
+			if err != nil {
+				return fmt.Errorf("print to pdf: %w", err)
+			}
+			return nil
+		}))
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	res := result.response()
+	res.Data = data
+	return res, nil
+}
+
+// emulateViewport resizes the page viewport to the given size,
+// filling in defaults for unset dimensions.
+// The returned function restores the original viewport.
+//
+// The caller must hold viewportMu.
+func (c *Compiler) emulateViewport(ctx context.Context, width, height int) (reset func(), err error) {
+	if width <= 0 {
+		width = _defaultViewportWidth
+	}
+	if height <= 0 {
+		height = _defaultViewportHeight
+	}
+
+	if err := chromedp.Run(ctx, chromedp.EmulateViewport(int64(width), int64(height))); err != nil {
+		return nil, fmt.Errorf("set viewport: %w", err)
+	}
+	return func() {
+		// Use the browser context so that this runs
+		// even if the request's context was canceled.
+		_ = chromedp.Run(c.ctx, chromedp.EmulateReset()) // ignore error
+	}, nil
+}
+
+// withElement renders the diagram into an element on the page
+// and calls fn with the ID of that element.
+// The element is removed after fn returns.
+func (c *Compiler) withElement(ctx context.Context, req *mermaid.CompileRequest, fn func(id string) error) (_ *renderResult, err error) {
+	script, err := callScript("renderElement", req.Source, newRenderOptions(req))
+	if err != nil {
+		return nil, err
+	}
+
+	var result renderResult
+	if err := chromedp.Run(ctx, evaluate(script, &result)); err != nil {
+		return nil, err
+	}
+	id := result.ID
+	defer func() {
+		cleanup, cerr := callScript("removeElement", id)
+		if cerr == nil {
+			// Use the browser context so that this runs
+			// even if the request's context was canceled.
+			// Otherwise, the element stays on the page
+			// and ends up in later PDFs.
+			var ok bool
+			cerr = chromedp.Run(c.ctx, evaluate(cleanup, &ok))
+		}
+		if err == nil && cerr != nil {
+			err = fmt.Errorf("remove rendered diagram: %w", cerr)
+		}
+	}()
+
+	if err := fn(id); err != nil {
+		return nil, err
+	}
+	return &result, nil
+}
+
+// callScript builds JavaScript that calls the named function
+// with the JSON-encoded arguments.
+func callScript(fn string, args ...any) (string, error) {
 	var script strings.Builder
-	script.WriteString("renderSVG(")
-	if err := json.NewEncoder(&script).Encode(req.Source); err != nil {
-		return nil, fmt.Errorf("encode source: %w", err)
+	script.WriteString(fn)
+	script.WriteString("(")
+	for i, arg := range args {
+		if i > 0 {
+			script.WriteString(",")
+		}
+		if err := json.NewEncoder(&script).Encode(arg); err != nil {
+			return "", fmt.Errorf("encode argument: %w", err)
+		}
 	}
 	script.WriteString(")")
+	return script.String(), nil
+}
 
-	// TODO: Can we use chromedp.CallFunctionOn instead?
-	var result string
-	render := chromedp.Evaluate(
-		script.String(),
-		&result,
+// evaluate evaluates the given script,
+// waiting for the promise it returns, if any.
+func evaluate(script string, result any) chromedp.Action {
+	return chromedp.Evaluate(
+		script,
+		result,
 		func(p *cdruntime.EvaluateParams) *cdruntime.EvaluateParams {
 			return p.WithAwaitPromise(true)
 		},
 	)
+}
 
-	c.mu.RLock()
-	defer c.mu.RUnlock()
-	if c.ctx == nil {
-		panic("Compiler is closed")
-	}
-	ctx, cancel := mergeCtxLifetime(c.ctx, ctx)
-	defer cancel()
+// CacheKey identifies the configuration of this compiler
+// for mermaid.CachingCompiler.
+// Compilers with the same MermaidJS source and theme
+// have the same key.
+func (c *Compiler) CacheKey(context.Context) string {
+	return c.cacheKey
+}
 
-	err := chromedp.Run(ctx, render)
-	return &mermaid.CompileResponse{
-		SVG: result,
-	}, err
+func cacheKey(cfg *Config) string {
+	sum := sha256.Sum256([]byte(cfg.JSSource))
+	return "mermaidjs=" + hex.EncodeToString(sum[:]) + " theme=" + cfg.Theme
 }
 
 // Close stops the compiler and releases any resources it holds.
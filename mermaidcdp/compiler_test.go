@@ -1,11 +1,15 @@
 package mermaidcdp
 
 import (
+	"bytes"
 	"context"
 	"flag"
+	"image"
+	"image/png"
 	"os"
 	"testing"
 
+	"github.com/chromedp/chromedp"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	"go.abhg.dev/goldmark/mermaid"
@@ -75,6 +79,242 @@ func TestCompiler_Compile(t *testing.T) {
 	}
 }
 
+func TestCompiler_Compile_png(t *testing.T) {
+	t.Parallel()
+
+	c, err := New(&Config{
+		JSSource:  loadMermaidJS(t),
+		NoSandbox: true,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, c.Close())
+	})
+
+	compile := func(t *testing.T, scale float64) image.Config {
+		res, err := c.Compile(context.Background(), &mermaid.CompileRequest{
+			Source: "graph TD; A-->B;",
+			Format: mermaid.FormatPNG,
+			Width:  300,
+			Scale:  scale,
+		})
+		require.NoError(t, err)
+		assert.Empty(t, res.SVG)
+
+		cfg, err := png.DecodeConfig(bytes.NewReader(res.Data))
+		require.NoError(t, err)
+		return cfg
+	}
+
+	one := compile(t, 1)
+	assert.InDelta(t, 300, one.Width, 1)
+
+	two := compile(t, 2)
+	assert.InDelta(t, 2*one.Width, two.Width, 2)
+	assert.InDelta(t, 2*one.Height, two.Height, 2)
+}
+
+func TestCompiler_Compile_viewport(t *testing.T) {
+	t.Parallel()
+
+	c, err := New(&Config{
+		JSSource:  loadMermaidJS(t),
+		NoSandbox: true,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, c.Close())
+	})
+
+	sized, err := c.Compile(context.Background(), &mermaid.CompileRequest{
+		Source: "graph TD; A-->B;",
+		Width:  1024,
+		Height: 768,
+	})
+	require.NoError(t, err)
+	assert.Contains(t, sized.SVG, "<svg")
+
+	// The viewport is restored afterwards.
+	unsized, err := c.Compile(context.Background(), &mermaid.CompileRequest{
+		Source: "graph TD; A-->B;",
+	})
+	require.NoError(t, err)
+	assert.Contains(t, unsized.SVG, "<svg")
+}
+
+func TestCompiler_Compile_maxEdges(t *testing.T) {
+	t.Parallel()
+
+	c, err := New(&Config{
+		JSSource:  loadMermaidJS(t),
+		NoSandbox: true,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, c.Close())
+	})
+
+	const src = "graph TD; A-->B; B-->C; C-->D;"
+	_, err = c.Compile(context.Background(), &mermaid.CompileRequest{
+		Source:   src,
+		MaxEdges: 2,
+	})
+	assert.ErrorContains(t, err, "Edge limit exceeded")
+
+	// The limit applies only to that request.
+	res, err := c.Compile(context.Background(), &mermaid.CompileRequest{
+		Source: src,
+	})
+	require.NoError(t, err)
+	assert.Contains(t, res.SVG, "<svg")
+}
+
+func TestCompiler_Compile_deterministic(t *testing.T) {
+	t.Parallel()
+
+	c, err := New(&Config{
+		JSSource:  loadMermaidJS(t),
+		NoSandbox: true,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, c.Close())
+	})
+
+	compile := func(src string) string {
+		res, err := c.Compile(context.Background(), &mermaid.CompileRequest{
+			Source:              src,
+			DeterministicIDSeed: "seed",
+		})
+		require.NoError(t, err)
+		return res.SVG
+	}
+
+	const src = "graph TD; A-->B; B-->C;"
+	first := compile(src)
+	compile("sequenceDiagram; Alice->>Bob: Hi")
+	assert.Equal(t, first, compile(src))
+}
+
+func TestCompiler_Compile_requestOptions(t *testing.T) {
+	t.Parallel()
+
+	c, err := New(&Config{
+		JSSource:  loadMermaidJS(t),
+		NoSandbox: true,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, c.Close())
+	})
+
+	const src = "graph TD; A-->B;"
+	res, err := c.Compile(context.Background(), &mermaid.CompileRequest{
+		Source:     src,
+		ID:         "my-diagram",
+		Theme:      "dark",
+		Background: "black",
+		Config: map[string]any{
+			"fontFamily": "my-font",
+		},
+	})
+	require.NoError(t, err)
+	assert.Contains(t, res.SVG, `id="my-diagram"`)
+	assert.Contains(t, res.SVG, "background-color: black")
+	assert.Contains(t, res.SVG, "my-font")
+
+	// The options apply only to that request.
+	res, err = c.Compile(context.Background(), &mermaid.CompileRequest{
+		Source: src,
+	})
+	require.NoError(t, err)
+	assert.Contains(t, res.SVG, `id="mermaid"`)
+	assert.NotContains(t, res.SVG, "background-color: black")
+	assert.NotContains(t, res.SVG, "my-font")
+}
+
+func TestCompiler_Compile_metadata(t *testing.T) {
+	t.Parallel()
+
+	c, err := New(&Config{
+		JSSource:  loadMermaidJS(t),
+		NoSandbox: true,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, c.Close())
+	})
+
+	const src = "graph TD\n" +
+		"  accTitle: Checkout\n" +
+		"  accDescr: How orders are placed\n" +
+		"  A-->B\n"
+	for _, format := range []mermaid.Format{mermaid.FormatSVG, mermaid.FormatPNG} {
+		res, err := c.Compile(context.Background(), &mermaid.CompileRequest{
+			Source: src,
+			Format: format,
+		})
+		require.NoError(t, err, "format %v", format)
+
+		assert.Positive(t, res.Width, "format %v", format)
+		assert.Positive(t, res.Height, "format %v", format)
+		assert.NotEmpty(t, res.ViewBox, "format %v", format)
+		assert.Contains(t, res.DiagramType, "flowchart", "format %v", format)
+		assert.Equal(t, "Checkout", res.Title, "format %v", format)
+		assert.Equal(t, "How orders are placed", res.Description, "format %v", format)
+		assert.Positive(t, res.Duration, "format %v", format)
+	}
+}
+
+func TestCompiler_Compile_pdf(t *testing.T) {
+	t.Parallel()
+
+	c, err := New(&Config{
+		JSSource:  loadMermaidJS(t),
+		NoSandbox: true,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, c.Close())
+	})
+
+	got, err := mermaid.CompilePDF(context.Background(), c, "graph TD; A-->B;")
+	require.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(got, []byte("%PDF-")), "not a PDF")
+}
+
+// TestCompiler_withElement_canceled verifies that rendered elements
+// are removed from the page even if the request is canceled.
+func TestCompiler_withElement_canceled(t *testing.T) {
+	t.Parallel()
+
+	c, err := New(&Config{
+		JSSource:  loadMermaidJS(t),
+		NoSandbox: true,
+	})
+	require.NoError(t, err)
+	t.Cleanup(func() {
+		assert.NoError(t, c.Close())
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var id string
+	_, err = c.withElement(ctx, &mermaid.CompileRequest{Source: "graph TD; A-->B;"}, func(elementID string) error {
+		id = elementID
+		cancel()
+		return ctx.Err()
+	})
+	require.ErrorIs(t, err, context.Canceled)
+	require.NotEmpty(t, id)
+
+	var remaining int
+	script := `document.querySelectorAll('[id^="mermaid-element-"]').length`
+	require.NoError(t, chromedp.Run(c.ctx, chromedp.Evaluate(script, &remaining)))
+	assert.Zero(t, remaining)
+}
+
 func TestCompiler_Compile_closed(t *testing.T) {
 	t.Parallel()
 
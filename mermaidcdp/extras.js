@@ -1,4 +1,183 @@
-async function renderSVG(src) {
-	const { svg } = await mermaid.render('mermaid', src);
-	return svg;
+// Configuration passed to mermaid.initialize by initialize.
+// Per-diagram configuration is layered on top of this.
+let baseConfig = {};
+
+function initialize(config) {
+	baseConfig = config;
+	mermaid.initialize(config);
+	return true;
+}
+
+let renderQueue = Promise.resolve();
+
+// Renders a diagram with the given configuration layered on top of
+// the base configuration.
+// Returns the result of mermaid.render
+// with the warnings Mermaid logged while rendering added as 'warnings'.
+//
+// Mermaid configuration is global so renders are run one at a time.
+// Mermaid already does this internally so this doesn't cost anything.
+function render(id, src, config) {
+	const result = renderQueue.then(async () => {
+		// Mermaid binds its logger to console.warn when it's initialized,
+		// so swap it out before initializing.
+		const warnings = [];
+		const warn = console.warn;
+		console.warn = (...args) => warnings.push(formatWarning(args));
+		try {
+			mermaid.initialize({ ...baseConfig, ...config, logLevel: 'warn' });
+			const result = await mermaid.render(id, src);
+			return { ...result, warnings };
+		} finally {
+			console.warn = warn;
+			mermaid.initialize(baseConfig);
+		}
+	});
+	renderQueue = result.catch(() => {});
+	return result;
+}
+
+// Formats the arguments of a console.warn call made by Mermaid's logger.
+function formatWarning(args) {
+	// The logger prefixes messages with a timestamp and a CSS style:
+	//   '%c12:00:00.000 : WARN : ', 'color: orange', ...
+	if (typeof args[0] === 'string' && args[0].startsWith('%c')) {
+		args = args.slice(2);
+	}
+	return args.map((arg) => {
+		if (typeof arg === 'string') {
+			return arg;
+		}
+		if (arg instanceof Error) {
+			return arg.message;
+		}
+		try {
+			return JSON.stringify(arg);
+		} catch (e) {
+			return String(arg);
+		}
+	}).join(' ').trim();
+}
+
+// Returns metadata about a rendered <svg> element.
+function describe(el, result) {
+	const metadata = {
+		diagramType: result.diagramType || el.getAttribute('aria-roledescription') || '',
+		viewBox: el.getAttribute('viewBox') || '',
+		warnings: result.warnings,
+	};
+
+	const viewBox = el.viewBox && el.viewBox.baseVal;
+	if (viewBox && viewBox.width && viewBox.height) {
+		metadata.width = viewBox.width;
+		metadata.height = viewBox.height;
+	}
+
+	const title = el.querySelector(':scope > title');
+	if (title) {
+		metadata.title = title.textContent.trim();
+	}
+	const desc = el.querySelector(':scope > desc');
+	if (desc) {
+		metadata.description = desc.textContent.trim();
+	}
+	return metadata;
+}
+
+// Renders a diagram into an SVG string.
+// Returns the SVG as 'svg' along with metadata from describe.
+//
+// options holds the following optional fields:
+//
+//  - id: ID of the <svg> element
+//  - background: CSS background color of the diagram
+//  - config: Mermaid configuration for this diagram
+async function renderSVG(src, options) {
+	const { id, background, config } = options || {};
+	const result = await render(id || 'mermaid', src, config);
+
+	const container = document.createElement('div');
+	container.innerHTML = result.svg;
+	const el = container.querySelector('svg');
+
+	let svg = result.svg;
+	if (background) {
+		el.style.backgroundColor = background;
+		svg = container.innerHTML;
+	}
+	return { svg, ...describe(el, result) };
+}
+
+let elementCounter = 0;
+
+// Renders a diagram into the page so that it can be
+// screenshotted or printed,
+// and returns the ID of the element holding it as 'id'
+// along with metadata from describe.
+// The element must be removed with removeElement afterwards.
+//
+// options holds the same fields as renderSVG except id,
+// and the following optional fields:
+//
+//  - width: width of the diagram in CSS pixels
+async function renderElement(src, options) {
+	const { width, background, config } = options || {};
+	const id = 'mermaid-element-' + (++elementCounter);
+	const result = await render(id + '-svg', src, config);
+
+	const container = document.createElement('div');
+	container.id = id;
+	container.style.display = 'inline-block';
+	container.innerHTML = result.svg;
+
+	// Size the SVG explicitly based on its viewBox.
+	// Mermaid sizes it relative to its container otherwise.
+	const el = container.querySelector('svg');
+	const viewBox = el.viewBox && el.viewBox.baseVal;
+	if (viewBox && viewBox.width && viewBox.height) {
+		const w = width || viewBox.width;
+		el.setAttribute('width', w);
+		el.setAttribute('height', viewBox.height * w / viewBox.width);
+		el.style.maxWidth = 'none';
+	}
+	if (background) {
+		el.style.backgroundColor = background;
+	}
+
+	document.body.appendChild(container);
+	return { id, ...describe(el, result) };
+}
+
+// Prepares the page to print only the element with the given ID
+// and returns its size in CSS pixels.
+// Only one element may be prepared for printing at a time.
+function preparePrint(id) {
+	// Drop print styles left behind by earlier diagrams, if any,
+	// so that they don't print on top of this one.
+	for (const el of document.querySelectorAll('style[id$="-print"]')) {
+		el.remove();
+	}
+
+	const style = document.createElement('style');
+	style.id = id + '-print';
+	style.textContent =
+		'@page { margin: 0; }' +
+		'@media print {' +
+		'  html, body { margin: 0; padding: 0; }' +
+		'  body > * { display: none !important; }' +
+		'  body > #' + id + ' { display: block !important; position: absolute; top: 0; left: 0; }' +
+		'}';
+	document.head.appendChild(style);
+
+	const rect = document.querySelector('#' + id + ' > svg').getBoundingClientRect();
+	return [rect.width, rect.height];
+}
+
+function removeElement(id) {
+	for (const el of [document.getElementById(id), document.getElementById(id + '-print')]) {
+		if (el) {
+			el.remove();
+		}
+	}
+	return true;
 }
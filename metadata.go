@@ -0,0 +1,45 @@
+package mermaid
+
+import "strings"
+
+// setSVGMetadata fills in the metadata fields of res
+// from the compiled SVG.
+// SVGs that can't be parsed are ignored.
+func setSVGMetadata(res *CompileResponse, svg string) {
+	doc, err := ParseSVG(svg)
+	if err != nil {
+		return
+	}
+	root := doc.Root()
+	if root == nil {
+		return
+	}
+
+	if viewBox, ok := root.Attribute("viewBox"); ok {
+		res.ViewBox = viewBox
+		res.Width, res.Height, _ = parseViewBox(viewBox)
+	}
+
+	// Mermaid marks the root with the type of the diagram.
+	if typ, ok := root.Attribute("aria-roledescription"); ok {
+		res.DiagramType = typ
+	}
+
+	for _, node := range root.Children {
+		el, ok := node.(*SVGElement)
+		if !ok {
+			continue
+		}
+
+		switch strings.ToLower(el.Name.Local) {
+		case "title":
+			if len(res.Title) == 0 {
+				res.Title = strings.TrimSpace(el.Text())
+			}
+		case "desc":
+			if len(res.Description) == 0 {
+				res.Description = strings.TrimSpace(el.Text())
+			}
+		}
+	}
+}
@@ -0,0 +1,58 @@
+package mermaid
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetSVGMetadata(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		give string
+		want CompileResponse
+	}{
+		{
+			name: "empty",
+			give: `<svg></svg>`,
+		},
+		{
+			name: "invalid",
+			give: `not an svg`,
+		},
+		{
+			name: "mermaid",
+			give: `<svg id="my-svg" viewBox="-8 -8 200.5 100" aria-roledescription="flowchart-v2"` +
+				` aria-labelledby="chart-title-my-svg" aria-describedby="chart-desc-my-svg">` +
+				`<title id="chart-title-my-svg">Checkout</title>` +
+				`<desc id="chart-desc-my-svg">How orders &amp; refunds work</desc>` +
+				`<g><title>Node</title></g></svg>`,
+			want: CompileResponse{
+				Width:       200.5,
+				Height:      100,
+				ViewBox:     "-8 -8 200.5 100",
+				DiagramType: "flowchart-v2",
+				Title:       "Checkout",
+				Description: "How orders & refunds work",
+			},
+		},
+		{
+			name: "bad viewBox",
+			give: `<svg viewBox="0 0 auto"></svg>`,
+			want: CompileResponse{ViewBox: "0 0 auto"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var got CompileResponse
+			setSVGMetadata(&got, tt.give)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
@@ -0,0 +1,174 @@
+package mermaid
+
+import (
+	"context"
+	"encoding/base64"
+	"fmt"
+	"html/template"
+	"time"
+
+	"github.com/yuin/goldmark/util"
+)
+
+// NoScriptFallback renders the contents of a <noscript> element
+// for a diagram rendered client-side.
+//
+// Readers that don't run JavaScript,
+// like text-mode browsers and search engine crawlers,
+// will see this instead of the raw Mermaid source.
+type NoScriptFallback interface {
+	// RenderNoScript writes HTML for the diagram with the given source.
+	// The output is placed inside a <noscript> element.
+	//
+	// ctx is the context for rendering the diagram,
+	// specified with WithContext.
+	RenderNoScript(ctx context.Context, w util.BufWriter, src string) error
+}
+
+// NoScriptSVG is a [NoScriptFallback] that renders the diagram
+// server-side with a [Compiler].
+type NoScriptSVG struct {
+	// Compiler used to render the diagram.
+	//
+	// If unspecified, this uses CLICompiler.
+	Compiler Compiler
+
+	// If true, the SVG is included as an <img> with a data URI
+	// instead of being inlined into the page.
+	Image bool
+
+	// Timeout is the maximum time allowed to compile a single diagram.
+	// See ServerRenderer.Timeout.
+	//
+	// Defaults to no timeout.
+	Timeout time.Duration
+
+	// MaxSourceSize is the maximum size of a diagram's source in bytes.
+	// See ServerRenderer.MaxSourceSize.
+	//
+	// Defaults to Mermaid's own limit.
+	MaxSourceSize int
+
+	// MaxEdges is the maximum number of edges in a diagram.
+	// See ServerRenderer.MaxEdges.
+	//
+	// Defaults to Mermaid's own limit.
+	MaxEdges int
+}
+
+var _ NoScriptFallback = (*NoScriptSVG)(nil)
+
+// RenderNoScript renders the diagram into an SVG.
+//
+// If the diagram fails to compile or exceeds the configured limits,
+// this renders its accessible title and description
+// like [NoScriptText].
+// If ctx is canceled, this returns its error.
+func (n *NoScriptSVG) RenderNoScript(ctx context.Context, w util.BufWriter, src string) error {
+	if n.MaxSourceSize > 0 && len(src) > n.MaxSourceSize {
+		return new(NoScriptText).RenderNoScript(ctx, w, src)
+	}
+
+	compiler := n.Compiler
+	if compiler == nil {
+		compiler = new(CLICompiler)
+	}
+
+	compileCtx := ctx
+	if n.Timeout > 0 {
+		var cancel context.CancelFunc
+		compileCtx, cancel = context.WithTimeout(ctx, n.Timeout)
+		defer cancel()
+	}
+
+	res, err := compiler.Compile(compileCtx, &CompileRequest{
+		Source:      src,
+		MaxTextSize: n.MaxSourceSize,
+		MaxEdges:    n.MaxEdges,
+	})
+	if err != nil {
+		// The conversion was canceled.
+		if ctxErr := ctx.Err(); ctxErr != nil {
+			return ctxErr
+		}
+		return new(NoScriptText).RenderNoScript(ctx, w, src)
+	}
+
+	if !n.Image {
+		_, err = w.WriteString(res.SVG)
+		return err
+	}
+
+	acc := parseAccessibility(src)
+	_, _ = w.WriteString(`<img src="data:image/svg+xml;base64,`)
+	_, _ = w.WriteString(base64.StdEncoding.EncodeToString([]byte(res.SVG)))
+	_, _ = w.WriteString(`" alt="`)
+	template.HTMLEscape(w, []byte(acc.AltText()))
+	_, err = w.WriteString(`">`)
+	return err
+}
+
+// NoScriptImage is a [NoScriptFallback] that renders an <img>
+// pointing to an externally hosted image of the diagram.
+type NoScriptImage struct {
+	// URL returns the URL of an image for the diagram
+	// with the given source.
+	//
+	// For example, this may point to an image generated
+	// ahead of time, or to a service that renders diagrams.
+	URL func(src string) string
+}
+
+var _ NoScriptFallback = (*NoScriptImage)(nil)
+
+// RenderNoScript renders an <img> tag for the diagram.
+// The diagram's accessible title or description is used as the alt text.
+func (n *NoScriptImage) RenderNoScript(_ context.Context, w util.BufWriter, src string) error {
+	if n.URL == nil {
+		return fmt.Errorf("NoScriptImage: URL must be specified")
+	}
+
+	acc := parseAccessibility(src)
+	_, _ = w.WriteString(`<img src="`)
+	template.HTMLEscape(w, []byte(n.URL(src)))
+	_, _ = w.WriteString(`" alt="`)
+	template.HTMLEscape(w, []byte(acc.AltText()))
+	_, err := w.WriteString(`">`)
+	return err
+}
+
+// NoScriptText is a [NoScriptFallback] that renders a text description
+// of the diagram from its accTitle and accDescr declarations.
+//
+//	accTitle: Request flow
+//	accDescr: Requests pass through the load balancer to the API servers.
+type NoScriptText struct {
+	// Default is the text to render for diagrams
+	// that don't declare an accessible title or description.
+	//
+	// If unspecified, nothing is rendered for such diagrams.
+	Default string
+}
+
+var _ NoScriptFallback = (*NoScriptText)(nil)
+
+// RenderNoScript renders the accessible title and description
+// of the diagram as paragraphs.
+func (n *NoScriptText) RenderNoScript(_ context.Context, w util.BufWriter, src string) error {
+	acc := parseAccessibility(src)
+	if len(acc.Title) == 0 && len(acc.Description) == 0 {
+		acc.Description = n.Default
+	}
+
+	if len(acc.Title) > 0 {
+		_, _ = w.WriteString("<p><strong>")
+		template.HTMLEscape(w, []byte(acc.AltText()))
+		_, _ = w.WriteString("</strong></p>")
+	}
+	if len(acc.Description) > 0 {
+		_, _ = w.WriteString("<p>")
+		template.HTMLEscape(w, []byte(acc.Description))
+		_, _ = w.WriteString("</p>")
+	}
+	return nil
+}
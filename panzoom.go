@@ -0,0 +1,56 @@
+package mermaid
+
+import (
+	_ "embed" // for go:embed
+	"encoding/json"
+	"strings"
+
+	"github.com/yuin/goldmark/util"
+)
+
+var (
+	//go:embed panzoom.js
+	_panZoomJS string
+
+	//go:embed panzoom.css
+	_panZoomCSS string
+)
+
+// Opening markup for the pan/zoom viewport around a server-rendered SVG.
+//
+// This must match the markup generated by panzoom.js
+// for diagrams rendered client-side.
+const _panZoomOpen = `<div class="mermaid-panzoom">` +
+	`<div class="mermaid-panzoom-controls" role="toolbar" aria-label="Diagram controls">` +
+	`<button type="button" data-panzoom="in" aria-label="Zoom in" title="Zoom in">+</button>` +
+	`<button type="button" data-panzoom="out" aria-label="Zoom out" title="Zoom out">−</button>` +
+	`<button type="button" data-panzoom="reset" aria-label="Reset zoom" title="Reset zoom">↺</button>` +
+	`<button type="button" data-panzoom="fullscreen" aria-label="Open full screen" title="Open full screen">⛶</button>` +
+	`</div>` +
+	`<div class="mermaid-panzoom-viewport">`
+
+const _panZoomClose = `</div></div>`
+
+// panZoomOptions defines options for panzoom.js.
+type panZoomOptions struct {
+	// Selector matches containers of diagrams rendered client-side.
+	Selector string `json:"selector"`
+}
+
+// writePanZoomAssets writes the stylesheet and script
+// that implement pan and zoom for diagrams.
+func writePanZoomAssets(w util.BufWriter, opts panZoomOptions) error {
+	b, err := json.Marshal(opts)
+	if err != nil {
+		return err
+	}
+
+	_, _ = w.WriteString("<style>")
+	_, _ = w.WriteString(_panZoomCSS)
+	_, _ = w.WriteString("</style><script>(")
+	_, _ = w.WriteString(strings.TrimSpace(_panZoomJS))
+	_, _ = w.WriteString(")(")
+	_, _ = w.Write(b)
+	_, _ = w.WriteString(");</script>")
+	return nil
+}
@@ -0,0 +1,151 @@
+function (opts) {
+	const minScale = 0.25;
+	const maxScale = 8;
+	const step = 1.25;
+
+	// Wraps an SVG rendered client-side in a pan/zoom viewport.
+	// Server-rendered diagrams already have this markup.
+	function wrap(svg) {
+		const viewport = document.createElement('div');
+		viewport.className = 'mermaid-panzoom-viewport';
+
+		const panzoom = document.createElement('div');
+		panzoom.className = 'mermaid-panzoom';
+		panzoom.appendChild(controls());
+		panzoom.appendChild(viewport);
+
+		svg.replaceWith(panzoom);
+		viewport.appendChild(svg);
+		return panzoom;
+	}
+
+	function controls() {
+		const bar = document.createElement('div');
+		bar.className = 'mermaid-panzoom-controls';
+		bar.setAttribute('role', 'toolbar');
+		bar.setAttribute('aria-label', 'Diagram controls');
+		for (const [action, label, text] of [
+			['in', 'Zoom in', '+'],
+			['out', 'Zoom out', '−'],
+			['reset', 'Reset zoom', '↺'],
+			['fullscreen', 'Open full screen', '⛶'],
+		]) {
+			const button = document.createElement('button');
+			button.type = 'button';
+			button.setAttribute('data-panzoom', action);
+			button.setAttribute('aria-label', label);
+			button.title = label;
+			button.textContent = text;
+			bar.appendChild(button);
+		}
+		return bar;
+	}
+
+	function attach(panzoom) {
+		if (panzoom.hasAttribute('data-panzoom-ready')) {
+			return;
+		}
+		const viewport = panzoom.querySelector('.mermaid-panzoom-viewport');
+		// Diagrams written as separate files are <img> tags.
+		const svg = viewport && viewport.querySelector('svg, img');
+		if (!svg) {
+			return;
+		}
+		panzoom.setAttribute('data-panzoom-ready', '');
+
+		let scale = 1, x = 0, y = 0;
+		function apply() {
+			svg.style.transformOrigin = '0 0';
+			svg.style.transform = 'translate(' + x + 'px,' + y + 'px) scale(' + scale + ')';
+		}
+
+		// Zooms by factor, keeping the point (px, py) of the viewport fixed.
+		function zoom(factor, px, py) {
+			const next = Math.min(maxScale, Math.max(minScale, scale * factor));
+			x = px - (px - x) * (next / scale);
+			y = py - (py - y) * (next / scale);
+			scale = next;
+			apply();
+		}
+
+		function center() {
+			return [viewport.clientWidth / 2, viewport.clientHeight / 2];
+		}
+
+		panzoom.querySelector('.mermaid-panzoom-controls').addEventListener('click', (e) => {
+			const button = e.target.closest('[data-panzoom]');
+			if (!button) {
+				return;
+			}
+			switch (button.getAttribute('data-panzoom')) {
+				case 'in':
+					zoom(step, ...center());
+					break;
+				case 'out':
+					zoom(1 / step, ...center());
+					break;
+				case 'reset':
+					scale = 1, x = 0, y = 0;
+					apply();
+					break;
+				case 'fullscreen':
+					if (document.fullscreenElement === panzoom) {
+						document.exitFullscreen();
+					} else if (panzoom.requestFullscreen) {
+						panzoom.requestFullscreen();
+					}
+					break;
+			}
+		});
+
+		viewport.addEventListener('wheel', (e) => {
+			e.preventDefault();
+			const rect = viewport.getBoundingClientRect();
+			zoom(e.deltaY < 0 ? step : 1 / step, e.clientX - rect.left, e.clientY - rect.top);
+		}, { passive: false });
+
+		let drag = null;
+		viewport.addEventListener('pointerdown', (e) => {
+			if (e.button !== 0) {
+				return;
+			}
+			drag = { id: e.pointerId, x: e.clientX - x, y: e.clientY - y };
+			viewport.setPointerCapture(e.pointerId);
+			viewport.classList.add('mermaid-panzoom-dragging');
+		});
+		viewport.addEventListener('pointermove', (e) => {
+			if (!drag || drag.id !== e.pointerId) {
+				return;
+			}
+			x = e.clientX - drag.x;
+			y = e.clientY - drag.y;
+			apply();
+		});
+		for (const type of ['pointerup', 'pointercancel']) {
+			viewport.addEventListener(type, (e) => {
+				if (drag && drag.id === e.pointerId) {
+					drag = null;
+					viewport.classList.remove('mermaid-panzoom-dragging');
+				}
+			});
+		}
+	}
+
+	function scan(root) {
+		for (const svg of root.querySelectorAll(opts.selector + ' > svg')) {
+			wrap(svg);
+		}
+		for (const panzoom of root.querySelectorAll('.mermaid-panzoom')) {
+			attach(panzoom);
+		}
+	}
+
+	scan(document);
+
+	// Diagrams rendered client-side appear after MermaidJS runs.
+	new MutationObserver((records) => {
+		if (records.some((r) => r.addedNodes.length > 0)) {
+			scan(document);
+		}
+	}).observe(document.body, { childList: true, subtree: true });
+}
@@ -0,0 +1,36 @@
+package mermaid
+
+import (
+	"bytes"
+	"context"
+	"errors"
+)
+
+var _pdfMagic = []byte("%PDF-")
+
+// CompilePDF compiles a single Mermaid diagram into a PDF document
+// with one page sized to fit the diagram.
+// The diagram is kept in vector form
+// so that it may be embedded into other documents,
+// for example with LaTeX's \includegraphics.
+//
+// If compiler is nil, CLICompiler is used.
+// The compiler must support FormatPDF.
+func CompilePDF(ctx context.Context, compiler Compiler, src string) ([]byte, error) {
+	if compiler == nil {
+		compiler = new(CLICompiler)
+	}
+
+	res, err := compiler.Compile(ctx, &CompileRequest{
+		Source: src,
+		Format: FormatPDF,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !bytes.HasPrefix(res.Data, _pdfMagic) {
+		return nil, errors.New("compiler did not produce a PDF document")
+	}
+	return res.Data, nil
+}
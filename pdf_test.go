@@ -0,0 +1,62 @@
+package mermaid
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCompilePDF(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name    string
+		res     *CompileResponse
+		err     error
+		want    string
+		wantErr string
+	}{
+		{
+			name: "success",
+			res:  &CompileResponse{Data: []byte("%PDF-1.7\n...")},
+			want: "%PDF-1.7\n...",
+		},
+		{
+			name:    "compile error",
+			err:     errors.New("great sadness"),
+			wantErr: "great sadness",
+		},
+		{
+			name:    "not a PDF",
+			res:     &CompileResponse{SVG: "<svg></svg>"},
+			wantErr: "compiler did not produce a PDF document",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			compiler := compilerStub{
+				CompileF: func(_ context.Context, req *CompileRequest) (*CompileResponse, error) {
+					assert.Equal(t, "graph TD; A-->B;", req.Source)
+					assert.Equal(t, FormatPDF, req.Format)
+					return tt.res, tt.err
+				},
+			}
+
+			got, err := CompilePDF(context.Background(), &compiler, "graph TD; A-->B;")
+			if len(tt.wantErr) > 0 {
+				assert.ErrorContains(t, err, tt.wantErr)
+				return
+			}
+
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, string(got))
+		})
+	}
+}
@@ -24,4 +24,12 @@ const (
 	//
 	// Fails rendering if the Mermaid CLI is absent.
 	RenderModeServer
+
+	// RenderModeHybrid renders Mermaid diagrams server-side when possible,
+	// falling back to client-side rendering for diagrams
+	// that fail to compile.
+	//
+	// The Mermaid <script> tags are added to the page
+	// only if at least one diagram fell back to client-side rendering.
+	RenderModeHybrid
 )
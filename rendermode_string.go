@@ -11,11 +11,12 @@ func _() {
 	_ = x[RenderModeAuto-0]
 	_ = x[RenderModeClient-1]
 	_ = x[RenderModeServer-2]
+	_ = x[RenderModeHybrid-3]
 }
 
-const _RenderMode_name = "AutoClientServer"
+const _RenderMode_name = "AutoClientServerHybrid"
 
-var _RenderMode_index = [...]uint8{0, 4, 10, 16}
+var _RenderMode_index = [...]uint8{0, 4, 10, 16, 22}
 
 func (i RenderMode) String() string {
 	idx := int(i) - 0
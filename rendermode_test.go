@@ -16,6 +16,7 @@ func TestRenderMode_String(t *testing.T) {
 		{RenderModeAuto, "Auto"},
 		{RenderModeClient, "Client"},
 		{RenderModeServer, "Server"},
+		{RenderModeHybrid, "Hybrid"},
 		{42, "RenderMode(42)"},
 	}
 
@@ -0,0 +1,352 @@
+package mermaid
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// SanitizeSVG is an [SVGTransformer] that makes SVGs safe to include
+// in pages when the diagrams come from untrusted sources.
+//
+// Use it with [ServerRenderer.Sanitize] to ensure that it runs
+// after all other transformers.
+//
+// It keeps only elements and attributes on an allowlist
+// of those used to draw diagrams.
+// Specifically, it removes:
+//
+//   - scripts, and elements that load or embed other documents,
+//     like <iframe>, <object>, and <foreignObject> content
+//     other than simple text formatting
+//   - animation elements, which can change attributes after sanitization
+//   - event handler attributes like onclick
+//   - links with URLs other than http, https, mailto, or relative URLs
+//   - references to external resources:
+//     <use> and url() may only refer to elements inside the SVG,
+//     and <image> may only hold data: URIs of raster images
+//   - style rules and declarations that load external resources
+//     or run code
+//   - comments, processing instructions, and DOCTYPE declarations
+//
+// Elements that aren't allowed are removed along with their contents.
+var SanitizeSVG SVGTransformer = SVGTransformerFunc(sanitizeSVG)
+
+// Elements allowed in sanitized SVGs, keyed by their lowercase names.
+//
+// This includes the HTML elements Mermaid uses
+// for labels inside <foreignObject>.
+var _sanitizeElements = newNameSet(
+	// SVG structure and shapes.
+	"svg", "g", "defs", "symbol", "use", "title", "desc", "style",
+	"path", "rect", "circle", "ellipse", "line", "polyline", "polygon",
+	"text", "tspan", "textPath", "a", "image", "switch",
+	"marker", "clipPath", "mask", "pattern",
+	"linearGradient", "radialGradient", "stop",
+	"filter", "feBlend", "feColorMatrix", "feComponentTransfer",
+	"feComposite", "feDropShadow", "feFlood", "feFuncA", "feFuncB",
+	"feFuncG", "feFuncR", "feGaussianBlur", "feMerge", "feMergeNode",
+	"feMorphology", "feOffset",
+	"foreignObject",
+
+	// HTML labels.
+	"div", "span", "p", "br", "b", "i", "em", "strong", "u", "s",
+	"del", "ins", "mark", "small", "sub", "sup", "code", "pre", "kbd",
+	"ul", "ol", "li", "hr", "h1", "h2", "h3", "h4", "h5", "h6",
+	"table", "thead", "tbody", "tfoot", "tr", "th", "td",
+	"blockquote",
+)
+
+// Attributes allowed in sanitized SVGs, keyed by their lowercase names.
+//
+// aria-*, data-*, and xmlns attributes are also allowed.
+// Attributes holding URLs are handled separately.
+// Event handlers like onclick are never allowed.
+var _sanitizeAttrs = newNameSet(
+	// Core.
+	"id", "class", "style", "lang", "xml:lang", "xml:space",
+	"dir", "role", "tabindex", "title",
+
+	// Geometry.
+	"x", "y", "x1", "x2", "y1", "y2", "cx", "cy", "r", "rx", "ry",
+	"fx", "fy", "dx", "dy", "d", "points", "pathLength",
+	"width", "height", "viewBox", "preserveAspectRatio", "transform",
+	"transform-origin", "rotate",
+
+	// Presentation.
+	"fill", "fill-opacity", "fill-rule", "clip-rule", "clip-path",
+	"mask", "filter", "opacity", "visibility", "display", "overflow",
+	"color", "cursor", "pointer-events",
+	"stroke", "stroke-width", "stroke-opacity", "stroke-linecap",
+	"stroke-linejoin", "stroke-miterlimit", "stroke-dasharray",
+	"stroke-dashoffset", "vector-effect", "paint-order",
+	"shape-rendering", "text-rendering", "color-interpolation-filters",
+	"marker-start", "marker-mid", "marker-end",
+	"font-family", "font-size", "font-style", "font-weight",
+	"font-variant", "text-anchor", "text-decoration",
+	"dominant-baseline", "alignment-baseline", "baseline-shift",
+	"letter-spacing", "word-spacing", "writing-mode",
+	"textLength", "lengthAdjust", "startOffset", "method", "spacing",
+
+	// Markers, gradients, patterns, clipping, and masking.
+	"markerUnits", "markerWidth", "markerHeight", "orient", "refX", "refY",
+	"gradientUnits", "gradientTransform", "spreadMethod", "offset",
+	"stop-color", "stop-opacity",
+	"patternUnits", "patternContentUnits", "patternTransform",
+	"clipPathUnits", "maskUnits", "maskContentUnits",
+
+	// Filters.
+	"filterUnits", "primitiveUnits", "in", "in2", "result",
+	"stdDeviation", "mode", "operator", "k1", "k2", "k3", "k4",
+	"type", "values", "tableValues", "slope", "intercept",
+	"amplitude", "exponent", "radius",
+	"flood-color", "flood-opacity",
+
+	// Document.
+	"version", "baseProfile", "focusable",
+
+	// Links. href is handled separately.
+	"target", "rel", "xlink:title",
+
+	// Tables.
+	"colspan", "rowspan", "align",
+)
+
+func newNameSet(names ...string) map[string]struct{} {
+	set := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		set[strings.ToLower(name)] = struct{}{}
+	}
+	return set
+}
+
+func sanitizeSVG(doc *SVGDocument) error {
+	doc.Nodes = sanitizeSVGNodes(doc.Nodes)
+	return nil
+}
+
+func sanitizeSVGNodes(nodes []SVGNode) []SVGNode {
+	kept := nodes[:0]
+	for _, n := range nodes {
+		switch n := n.(type) {
+		case *SVGText:
+			// Escaped when written.
+
+		case *SVGElement:
+			if !sanitizeSVGElement(n) {
+				continue
+			}
+
+		default:
+			// Comments, processing instructions, and directives.
+			continue
+		}
+		kept = append(kept, n)
+	}
+	return kept
+}
+
+// sanitizeSVGElement sanitizes e and its contents in place.
+// It reports false if e should be removed.
+func sanitizeSVGElement(e *SVGElement) bool {
+	if len(e.Name.Space) > 0 {
+		return false
+	}
+	name := strings.ToLower(e.Name.Local)
+	if _, ok := _sanitizeElements[name]; !ok {
+		return false
+	}
+
+	attrs := e.Attr[:0]
+	for _, attr := range e.Attr {
+		if value, ok := sanitizeSVGAttr(name, attr.Name.Space, attr.Name.Local, attr.Value); ok {
+			attr.Value = value
+			attrs = append(attrs, attr)
+		}
+	}
+	e.Attr = attrs
+
+	if name == "style" {
+		var css strings.Builder
+		for _, c := range e.Children {
+			if t, ok := c.(*SVGText); ok {
+				css.WriteString(t.Data)
+			}
+		}
+		e.Children = e.Children[:0]
+		if s := sanitizeStylesheet(css.String()); len(s) > 0 {
+			e.Children = append(e.Children, &SVGText{Data: s})
+		}
+		return true
+	}
+
+	e.Children = sanitizeSVGNodes(e.Children)
+	return true
+}
+
+// sanitizeSVGAttr returns the sanitized value of an attribute
+// of the given element,
+// or false if the attribute should be removed.
+func sanitizeSVGAttr(elem, space, local, value string) (string, bool) {
+	local = strings.ToLower(local)
+	space = strings.ToLower(space)
+
+	name := local
+	switch space {
+	case "":
+	case "xmlns":
+		return value, true
+	case "xlink", "xml":
+		name = space + ":" + local
+	default:
+		return "", false
+	}
+
+	switch {
+	case name == "href" || name == "xlink:href":
+		return value, safeHref(elem, value)
+
+	case name == "xmlns":
+		return value, true
+
+	case name == "style":
+		return sanitizeDeclarations(value), true
+
+	case strings.HasPrefix(name, "aria-"), strings.HasPrefix(name, "data-"):
+		return value, true
+	}
+
+	if _, ok := _sanitizeAttrs[name]; !ok {
+		return "", false
+	}
+	// Presentation attributes like fill and filter
+	// may hold url() references.
+	return value, safeCSS(value)
+}
+
+// safeHref reports whether value is safe as the href
+// of the given element.
+func safeHref(elem, value string) bool {
+	ref := strings.TrimSpace(value)
+	switch elem {
+	case "a":
+		return safeLinkURL(ref)
+	case "image":
+		return _dataImageRe.MatchString(ref)
+	default:
+		// <use>, <textPath>, gradients, etc.
+		// may only refer to elements in the same document.
+		return strings.HasPrefix(ref, "#")
+	}
+}
+
+// Data URIs for raster images.
+// SVG images are excluded since they may carry their own content.
+var _dataImageRe = regexp.MustCompile(`(?i)^data:image/(?:png|jpeg|gif|webp);base64,[a-z0-9+/=\s]*$`)
+
+// safeLinkURL reports whether url is safe to link to.
+// Only http, https, and mailto URLs, and URLs without a scheme,
+// are allowed.
+func safeLinkURL(url string) bool {
+	// Browsers ignore whitespace and control characters
+	// inside the scheme, so "java\tscript:" is "javascript:".
+	url = strings.Map(func(r rune) rune {
+		if r <= ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, url)
+
+	scheme, _, ok := strings.Cut(url, ":")
+	if !ok || strings.ContainsAny(scheme, "/?#") {
+		// No scheme. A relative URL.
+		return true
+	}
+	switch strings.ToLower(scheme) {
+	case "http", "https", "mailto":
+		return true
+	default:
+		return false
+	}
+}
+
+// sanitizeDeclarations removes unsafe declarations
+// from an inline style attribute.
+func sanitizeDeclarations(style string) string {
+	decls := splitCSS(style, ';')
+	kept := decls[:0]
+	for _, decl := range decls {
+		if safeCSS(decl) {
+			kept = append(kept, decl)
+		}
+	}
+	return strings.Join(kept, ";")
+}
+
+// sanitizeStylesheet removes unsafe rules from a stylesheet.
+//
+// Rules that are unsafe anywhere, including inside @media blocks,
+// are removed entirely.
+func sanitizeStylesheet(css string) string {
+	rules := splitCSSRules(_cssCommentRe.ReplaceAllString(css, ""))
+	var sb strings.Builder
+	for _, rule := range rules {
+		if safeCSS(rule) {
+			sb.WriteString(rule)
+		}
+	}
+	return sb.String()
+}
+
+var (
+	_cssCommentRe = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	_cssEscapeRe  = regexp.MustCompile(`\\(?:([0-9a-fA-F]{1,6})\s?|(.))`)
+	_cssURLRe     = regexp.MustCompile(`url\(\s*(['"]?)([^'")]*)`)
+)
+
+// Constructs that run code or change behavior in old browsers.
+var _unsafeCSS = []string{
+	"expression(", "javascript:", "vbscript:",
+	"-moz-binding", "behavior:", "@import", "@font-face", "image-set(",
+}
+
+// safeCSS reports whether a piece of CSS doesn't load external resources
+// or run code.
+// url() may only refer to elements in the same document.
+func safeCSS(css string) bool {
+	css = unescapeCSS(_cssCommentRe.ReplaceAllString(css, ""))
+	css = strings.ToLower(strings.Join(strings.Fields(css), ""))
+
+	for _, s := range _unsafeCSS {
+		if strings.Contains(css, s) {
+			return false
+		}
+	}
+
+	for _, m := range _cssURLRe.FindAllStringSubmatch(css, -1) {
+		if !strings.HasPrefix(m[2], "#") {
+			return false
+		}
+	}
+	return true
+}
+
+// unescapeCSS replaces CSS escape sequences like "\75" and "\r"
+// with the characters they stand for.
+func unescapeCSS(css string) string {
+	if !strings.Contains(css, `\`) {
+		return css
+	}
+
+	return _cssEscapeRe.ReplaceAllStringFunc(css, func(m string) string {
+		sub := _cssEscapeRe.FindStringSubmatch(m)
+		if len(sub[1]) == 0 {
+			return sub[2]
+		}
+		r, err := strconv.ParseUint(sub[1], 16, 32)
+		if err != nil || r == 0 || r > 0x10ffff {
+			return "�"
+		}
+		return string(rune(r))
+	})
+}
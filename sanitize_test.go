@@ -0,0 +1,214 @@
+package mermaid
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSanitizeSVG(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		give string
+		want string
+	}{
+		{
+			name: "script element",
+			give: `<svg><script>alert(1)</script><g/></svg>`,
+			want: `<svg><g/></svg>`,
+		},
+		{
+			name: "script in label",
+			give: `<svg><foreignObject><div><span>A<script>alert(1)</script></span></div></foreignObject></svg>`,
+			want: `<svg><foreignObject><div><span>A</span></div></foreignObject></svg>`,
+		},
+		{
+			name: "uppercase script",
+			give: `<svg><SCRIPT>alert(1)</SCRIPT></svg>`,
+			want: `<svg></svg>`,
+		},
+		{
+			name: "namespaced script",
+			give: `<svg><svg:script>alert(1)</svg:script><html:script>alert(1)</html:script></svg>`,
+			want: `<svg></svg>`,
+		},
+		{
+			name: "event handlers",
+			give: `<svg onload="alert(1)"><g onclick="alert(1)" ONMOUSEOVER="alert(1)" class="node"/></svg>`,
+			want: `<svg><g class="node"/></svg>`,
+		},
+		{
+			name: "img onerror in label",
+			give: `<svg><foreignObject><div><img src="x" onerror="alert(1)">B</div></foreignObject></svg>`,
+			want: `<svg><foreignObject><div>B</div></foreignObject></svg>`,
+		},
+		{
+			name: "embedded documents",
+			give: `<svg><foreignObject><iframe src="https://example.com"></iframe>` +
+				`<object data="x.swf"></object><embed src="x.swf"/>` +
+				`<form action="https://example.com"><button formaction="javascript:alert(1)">X</button></form>` +
+				`<math><mtext><style>x</style></mtext></math>` +
+				`<meta http-equiv="refresh" content="0;url=https://example.com"/>` +
+				`</foreignObject></svg>`,
+			want: `<svg><foreignObject></foreignObject></svg>`,
+		},
+		{
+			name: "click javascript link",
+			give: `<svg><a xlink:href="javascript:alert(1)" target="_blank"><g class="node"/></a></svg>`,
+			want: `<svg><a target="_blank"><g class="node"/></a></svg>`,
+		},
+		{
+			name: "obfuscated javascript links",
+			give: `<svg>` +
+				`<a href=" JaVaScRiPt:alert(1)"/>` +
+				`<a href="java&#x09;script:alert(1)"/>` +
+				`<a href="&#x0A;javascript:alert(1)"/>` +
+				`<a href="data:text/html;base64,PHNjcmlwdD5hbGVydCgxKTwvc2NyaXB0Pg=="/>` +
+				`<a href="vbscript:msgbox(1)"/>` +
+				`</svg>`,
+			want: `<svg><a/><a/><a/><a/><a/></svg>`,
+		},
+		{
+			name: "safe links",
+			give: `<svg>` +
+				`<a xlink:href="https://example.com/a?b=c"/>` +
+				`<a href="http://example.com"/>` +
+				`<a href="mailto:a@example.com"/>` +
+				`<a href="/docs/page#section"/>` +
+				`<a href="page.html?x=javascript:1"/>` +
+				`</svg>`,
+			want: `<svg>` +
+				`<a xlink:href="https://example.com/a?b=c"/>` +
+				`<a href="http://example.com"/>` +
+				`<a href="mailto:a@example.com"/>` +
+				`<a href="/docs/page#section"/>` +
+				`<a href="page.html?x=javascript:1"/>` +
+				`</svg>`,
+		},
+		{
+			name: "external use",
+			give: `<svg><use href="https://example.com/x.svg#a"/><use xlink:href="#local"/></svg>`,
+			want: `<svg><use/><use xlink:href="#local"/></svg>`,
+		},
+		{
+			name: "images",
+			give: `<svg>` +
+				`<image href="https://tracker.example.com/pixel.png"/>` +
+				`<image href="data:image/svg+xml;base64,PHN2Zz48L3N2Zz4="/>` +
+				`<image href="data:image/png;base64,iVBORw0KGgo="/>` +
+				`</svg>`,
+			want: `<svg><image/><image/><image href="data:image/png;base64,iVBORw0KGgo="/></svg>`,
+		},
+		{
+			name: "animation",
+			give: `<svg><a><animate attributeName="href" to="javascript:alert(1)"/>` +
+				`<set attributeName="onclick" to="alert(1)"/></a></svg>`,
+			want: `<svg><a></a></svg>`,
+		},
+		{
+			name: "url references",
+			give: `<svg><path fill="url(#gradient)" marker-end="url(https://example.com/m.svg#m)" filter="url( 'http://example.com/f' )"/></svg>`,
+			want: `<svg><path fill="url(#gradient)"/></svg>`,
+		},
+		{
+			name: "style attribute",
+			give: `<svg><g style="fill: red; background: url(javascript:alert(1)); stroke: blue"/>` +
+				`<div style="behavior: url(x.htc); -moz-binding: url(x.xml#xss); width: expression(alert(1)); color: red"/>` +
+				`<div style="background: u\72 l(https://example.com/x.png); background-image: \75rl(https://example.com)"/></svg>`,
+			want: `<svg><g style="fill: red; stroke: blue"/>` +
+				`<div style=" color: red"/>` +
+				`<div style=""/></svg>`,
+		},
+		{
+			name: "stylesheet",
+			give: `<svg><style>` +
+				`@import url(https://example.com/x.css);` +
+				`@import "https://example.com/y.css";` +
+				`#a .node{fill:#fff;}` +
+				`#a .bg{background:url(https://example.com/track.png);}` +
+				`@font-face{font-family:x;src:url(https://example.com/x.woff);}` +
+				`#a .x{width:expr/**/ession(alert(1));}` +
+				`#a .marker{marker-end:url(#a_arrow);}` +
+				`</style></svg>`,
+			want: `<svg><style>#a .node{fill:#fff;}#a .marker{marker-end:url(#a_arrow);}</style></svg>`,
+		},
+		{
+			name: "unknown attributes",
+			give: `<svg><g foo="bar" formaction="x" xlink:actuate="onLoad" ev:event="click" data-id="A" aria-label="A"/></svg>`,
+			want: `<svg><g data-id="A" aria-label="A"/></svg>`,
+		},
+		{
+			name: "comments and declarations",
+			give: `<?xml version="1.0"?><!DOCTYPE svg [<!ENTITY x "y">]><!-- a --><svg><!--<script>--></svg>`,
+			want: `<svg></svg>`,
+		},
+		{
+			name: "escaped text",
+			give: `<svg><text>&lt;script&gt;alert(1)&lt;/script&gt;</text></svg>`,
+			want: `<svg><text>&lt;script&gt;alert(1)&lt;/script&gt;</text></svg>`,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := transformSVG(tt.give, []SVGTransformer{SanitizeSVG})
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// TestSanitizeSVG_golden verifies that sanitizing SVGs generated by Mermaid
+// leaves them unchanged.
+func TestSanitizeSVG_golden(t *testing.T) {
+	t.Parallel()
+
+	for i, svg := range goldenSVGs(t) {
+		i, svg := i, svg
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Parallel()
+
+			want, err := transformSVG(svg, nil)
+			require.NoError(t, err)
+
+			got, err := transformSVG(svg, []SVGTransformer{SanitizeSVG})
+			require.NoError(t, err)
+			assert.Equal(t, want, got)
+		})
+	}
+}
+
+func TestSafeLinkURL(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		give string
+		want bool
+	}{
+		{"", true},
+		{"#top", true},
+		{"page.html", true},
+		{"/a/b:c", true},
+		{"?q=a:b", true},
+		{"https://example.com", true},
+		{"HTTP://example.com", true},
+		{"mailto:a@example.com", true},
+		{"javascript:alert(1)", false},
+		{"java\nscript:alert(1)", false},
+		{"\x00javascript:alert(1)", false},
+		{"data:text/html,x", false},
+		{"file:///etc/passwd", false},
+		{"ftp://example.com", false},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, safeLinkURL(tt.give), "%q", tt.give)
+	}
+}
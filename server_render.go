@@ -1,10 +1,15 @@
 package mermaid
 
 import (
-	"bytes"
 	"context"
+	"encoding/base64"
+	"errors"
 	"fmt"
-	"html/template"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/yuin/goldmark/ast"
 	"github.com/yuin/goldmark/renderer"
@@ -21,13 +26,129 @@ type Compiler interface {
 type CompileRequest struct {
 	// Source is the raw Mermaid diagram source.
 	Source string
+
+	// Format is the image format to compile the diagram into.
+	//
+	// Defaults to FormatSVG.
+	Format Format
+
+	// ID is the id attribute of the generated <svg> element.
+	//
+	// If unset, the compiler's default is used.
+	ID string
+
+	// Theme is the Mermaid theme to render the diagram with.
+	//
+	// Values include "dark", "default", "forest", and "neutral".
+	// See MermaidJS documentation for a full list.
+	//
+	// If unset, the compiler's theme is used.
+	Theme string
+
+	// Config holds Mermaid configuration for this diagram
+	// on top of the compiler's configuration.
+	// It must be encodable as JSON.
+	// For example:
+	//
+	//	map[string]any{
+	//		"flowchart": map[string]any{"curve": "linear"},
+	//	}
+	//
+	// Other fields of the request that map to Mermaid settings,
+	// like Theme and MaxEdges, take precedence over this.
+	// See https://mermaid.js.org/config/schema-docs/config.html.
+	Config map[string]any
+
+	// Background is the CSS background color of the diagram.
+	// For example, "white", "transparent", or "#f8f8f8".
+	//
+	// If unset, the compiler's default is used.
+	Background string
+
+	// Width is the width of the diagram in pixels.
+	//
+	// If unset, the compiler's default is used.
+	Width int
+
+	// Height is the height of the page the diagram is rendered in,
+	// in pixels.
+	// Most diagrams aren't affected by this.
+	//
+	// If unset, the compiler's default is used.
+	Height int
+
+	// Scale is the ratio of image pixels to diagram pixels
+	// for raster formats like PNG.
+	// Use 2 for sharp images on high density displays.
+	//
+	// Defaults to 1.
+	Scale float64
+
+	// MaxTextSize is the maximum number of characters in Source
+	// that Mermaid will render.
+	// This is Mermaid's maxTextSize setting.
+	//
+	// If unset, Mermaid's default is used.
+	MaxTextSize int
+
+	// MaxEdges is the maximum number of edges
+	// allowed in a diagram.
+	// Diagrams with more edges fail to compile.
+	// This is Mermaid's maxEdges setting.
+	//
+	// If unset, Mermaid's default is used.
+	MaxEdges int
+
+	// DeterministicIDSeed, if set, makes Mermaid generate IDs
+	// from this seed instead of randomly
+	// so that compiling the same diagram produces the same output.
+	// This sets Mermaid's deterministicIds and deterministicIDSeed settings.
+	//
+	// This also hides the marker for today's date in Gantt charts.
+	DeterministicIDSeed string
 }
 
 // CompileResponse is a response from compiling a Mermaid diagram.
+//
+// Compilers fill in metadata about the diagram
+// like its size and type where available.
+// Fields that a compiler couldn't determine are left empty.
 type CompileResponse struct {
 	// SVG holds the SVG diagram text
 	// including the <svg>...</svg> tags.
+	//
+	// This is set only for FormatSVG.
 	SVG string
+
+	// Data holds the compiled image for binary formats
+	// like FormatPNG.
+	Data []byte
+
+	// Width and Height are the intrinsic size of the diagram
+	// in CSS pixels, based on its viewBox.
+	Width, Height float64
+
+	// ViewBox is the viewBox attribute of the root <svg> element.
+	// For example, "0 0 200 100".
+	ViewBox string
+
+	// DiagramType is the type of the diagram as detected by Mermaid.
+	// For example, "flowchart-v2" or "sequence".
+	DiagramType string
+
+	// Title and Description are the accessible title and description
+	// of the diagram, specified with accTitle and accDescr.
+	Title, Description string
+
+	// Duration is how long the diagram took to compile.
+	//
+	// For responses served by CachingCompiler,
+	// this is the duration of the original compilation.
+	Duration time.Duration
+
+	// Warnings holds warnings Mermaid reported
+	// while compiling the diagram.
+	Warnings []string
 }
 
 // ServerRenderer renders Mermaid diagrams into images server-side.
@@ -48,64 +169,850 @@ type ServerRenderer struct {
 	//
 	// Defaults to "div".
 	ContainerTag string
+
+	// ContainerClass is the class attribute of the container.
+	// Classes specified on the fenced code block are added to it.
+	//
+	// Containers rendered by ServerRenderer additionally have
+	// the "mermaid-rendered" class and the data-processed attribute
+	// so that MermaidJS included on the same page leaves them alone.
+	//
+	// Defaults to "mermaid".
+	ContainerClass string
+
+	// ContainerAttributes are additional attributes
+	// for the container element.
+	// For example, data-* attributes.
+	ContainerAttributes map[string]string
+
+	// ErrorHandler decides what to do when a diagram fails to compile.
+	//
+	// Defaults to FailOnError, which stops rendering the document.
+	// Ignored if Fallback is set,
+	// except for diagrams that exceed a limit.
+	ErrorHandler ErrorHandler
+
+	// Concurrency is the maximum number of diagrams
+	// that will be compiled at the same time.
+	//
+	// If greater than one, all diagrams in a document
+	// are compiled concurrently before the first one is rendered.
+	// Otherwise, diagrams are compiled one at a time as they're rendered.
+	//
+	// The Compiler must be safe for concurrent use
+	// to use this.
+	Concurrency int
+
+	// Timeout is the maximum time allowed to compile a single diagram.
+	// Diagrams that take longer fail with a [LimitError]
+	// wrapping context.DeadlineExceeded,
+	// and are handled by ErrorHandler, never by Fallback.
+	//
+	// Use WithContext to limit the time taken by the entire document.
+	//
+	// Defaults to no timeout.
+	Timeout time.Duration
+
+	// MaxSourceSize is the maximum size of a diagram's source in bytes.
+	// Larger diagrams aren't compiled
+	// and are reported as a [LimitError].
+	// This is also passed to Mermaid as its maxTextSize setting.
+	//
+	// Defaults to no limit.
+	MaxSourceSize int
+
+	// MaxDiagrams is the maximum number of diagrams
+	// compiled in a single document.
+	// Diagrams past this limit aren't compiled
+	// and are reported as a [LimitError].
+	//
+	// Defaults to no limit.
+	MaxDiagrams int
+
+	// MaxEdges is the maximum number of edges in a diagram.
+	// Diagrams with more edges fail to compile
+	// and are reported as a [LimitError].
+	// This is passed to Mermaid as its maxEdges setting.
+	//
+	// Defaults to Mermaid's limit.
+	MaxEdges int
+
+	// Assets, if set, writes compiled diagrams as separate files
+	// and references them from the document with <img> tags
+	// instead of inlining them.
+	// File names are derived from a hash of the diagram.
+	//
+	// Use DirAssetWriter, AssetFS, or your own implementation.
+	Assets AssetWriter
+
+	// AssetURLPrefix is the URL prefix for files written to Assets.
+	// The file name is appended to it to build the image URL.
+	//
+	// Defaults to "/assets/mermaid/".
+	AssetURLPrefix string
+
+	// Format is the image format to compile diagrams into.
+	// Only FormatSVG and FormatPNG are supported.
+	//
+	// SVG diagrams are inlined into the document
+	// unless Assets is set.
+	// PNG diagrams are embedded as data URIs
+	// unless Assets is set.
+	//
+	// Defaults to FormatSVG.
+	Format Format
+
+	// Scale is the ratio of image pixels to diagram pixels
+	// for FormatPNG.
+	// Use 2 for sharp images on high density displays.
+	//
+	// Defaults to 1.
+	Scale float64
+
+	// Theme is the Mermaid theme to compile diagrams with.
+	// See CompileRequest.Theme.
+	//
+	// If unset, the Compiler's theme is used.
+	Theme string
+
+	// MermaidConfig holds Mermaid configuration
+	// to compile diagrams with.
+	// See CompileRequest.Config.
+	MermaidConfig map[string]any
+
+	// Background is the CSS background color of compiled diagrams.
+	// See CompileRequest.Background.
+	//
+	// If unset, the Compiler's default is used.
+	Background string
+
+	// Deterministic makes compiling the same diagram
+	// always produce the same output
+	// so that rebuilding a site doesn't change diagrams
+	// that haven't changed.
+	//
+	// Mermaid generates IDs from a seed based on the diagram source
+	// instead of randomly,
+	// Gantt charts don't mark today's date,
+	// and numbers in coordinates and sizes are rounded
+	// to remove small differences in layout between machines.
+	Deterministic bool
+
+	// SharedStyles moves the theme stylesheet out of each inline SVG
+	// into a single stylesheet shared by all diagrams in the document,
+	// written in place of the document's [StyleBlock].
+	// Each SVG keeps only the rules specific to it,
+	// like those for classDef.
+	//
+	// The shared stylesheet goes through SVGTransformers and Sanitize
+	// like the stylesheets left inside each SVG.
+	// With Toolbar, downloaded images get the theme rules back.
+	//
+	// This substantially shrinks pages with many diagrams.
+	// It applies only to SVGs inlined into the document.
+	SharedStyles bool
+
+	// Sizing controls the dimensions of rendered diagrams.
+	//
+	//	Sizing: mermaid.Sizing{Mode: mermaid.SizeMaxWidth, Width: 600}
+	//
+	// Individual diagrams may override this
+	// with the "width", "max-width", or "size" attributes
+	// of the fenced code block.
+	//
+	//	```mermaid {width=400}
+	//	```mermaid {size=fill}
+	//
+	// Defaults to Mermaid's own sizing.
+	Sizing Sizing
+
+	// UniqueIDs rewrites the IDs inside each compiled SVG
+	// so that they don't collide with other diagrams on the page.
+	// References to the IDs, such as url(#...) and CSS selectors,
+	// are updated to match.
+	//
+	// IDs are prefixed with the ID of the diagram's container
+	// followed by "-svg".
+	// Characters not allowed in CSS identifiers, like "." and ":",
+	// are replaced with "-" in the prefix.
+	// Diagrams without an ID use one based on their contents.
+	//
+	// Without this, multiple inline diagrams on the same page
+	// may share IDs, causing arrows and styles to be mixed up.
+	UniqueIDs bool
+
+	// Accessible makes compiled diagrams accessible to screen readers.
+	//
+	// Inline SVGs get role="img" and <title> and <desc> elements
+	// referenced by aria-labelledby and aria-describedby.
+	// Images get alt text.
+	//
+	// The title and description are taken from the "title" (or "alt")
+	// and "desc" attributes of the fenced code block,
+	// or from accTitle and accDescr in the diagram.
+	//
+	//	```mermaid {alt="Checkout flow"}
+	//
+	// Diagrams without either are reported to Diagnostics.
+	Accessible bool
+
+	// Diagnostics, if set, receives problems found with diagrams
+	// that don't stop them from rendering.
+	// For example, diagrams without an accessible name
+	// when Accessible is set,
+	// and warnings reported by the Compiler in CompileResponse.Warnings.
+	Diagnostics DiagnosticHandler
+
+	// SVGTransformers modify compiled SVGs
+	// before they're written to the document,
+	// in the order they're listed.
+	// They run after SharedStyles, Deterministic, UniqueIDs,
+	// Accessible, and Sizing, and before Sanitize.
+	//
+	// Use SetSVGAttributes, RemoveSVGStyles, FixSVGDimensions,
+	// StripSVGComments, or your own SVGTransformerFunc.
+	SVGTransformers []SVGTransformer
+
+	// Sanitize removes anything that could run scripts
+	// or load external resources from compiled SVGs
+	// using SanitizeSVG.
+	// Enable this if diagrams come from untrusted sources,
+	// like user-submitted Markdown.
+	//
+	// This runs after all SVGTransformers.
+	Sanitize bool
+
+	// Fallback, if set, renders diagrams client-side
+	// when they fail to compile server-side
+	// instead of failing the entire document.
+	//
+	// Diagrams that exceed a limit like MaxSourceSize or Timeout
+	// are never rendered client-side
+	// since they'd be just as expensive for readers' browsers.
+	// These are handled by ErrorHandler instead.
+	//
+	// The Mermaid <script> tags are rendered by Fallback
+	// only if at least one diagram in the document fell back to it.
+	Fallback *ClientRenderer
+
+	// PanZoom wraps rendered diagrams in a viewport
+	// that supports panning and zooming with the mouse,
+	// with buttons to zoom and to view the diagram full screen.
+	//
+	// The supporting script and stylesheet are rendered
+	// in place of the document's [ScriptBlock].
+	PanZoom bool
+
+	// Toolbar adds a toolbar to each diagram
+	// with buttons to view or copy the diagram source,
+	// and to download the diagram as an SVG or PNG image.
+	//
+	// The diagram, its toolbar, and a hidden copy of its source
+	// are wrapped in a <div class="mermaid-figure">.
+	// The supporting script and stylesheet are rendered
+	// in place of the document's [ScriptBlock].
+	Toolbar bool
 }
 
 // RegisterFuncs registers the renderer for Mermaid blocks with the provided
 // Goldmark Registerer.
 func (r *ServerRenderer) RegisterFuncs(reg renderer.NodeRendererFuncRegisterer) {
 	reg.Register(Kind, r.Render)
+	reg.Register(ScriptKind, r.RenderScript)
+	reg.Register(StyleKind, r.RenderStyle)
+}
+
+// RenderStyle renders [StyleBlock] nodes.
+//
+// This renders the stylesheet shared by diagrams in the document
+// if SharedStyles is set.
+// All diagrams in the document are compiled
+// before the stylesheet is rendered.
+func (r *ServerRenderer) RenderStyle(w util.BufWriter, src []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	if !entering || !r.sharedStyles() {
+		return ast.WalkContinue, nil
+	}
+
+	doc := node.OwnerDocument()
+	if doc == nil {
+		return ast.WalkContinue, nil
+	}
+
+	var (
+		themes []*themeStyle
+		seen   = make(map[string]struct{})
+	)
+	_ = ast.Walk(doc, func(node ast.Node, enter bool) (ast.WalkStatus, error) {
+		b, ok := node.(*Block)
+		if !ok || !enter || b.Lines().Len() == 0 {
+			return ast.WalkContinue, nil
+		}
+
+		if t := r.theme(src, b); t != nil {
+			if _, ok := seen[t.Class]; !ok {
+				seen[t.Class] = struct{}{}
+				themes = append(themes, t)
+			}
+		}
+		return ast.WalkContinue, nil
+	})
+
+	if len(themes) == 0 {
+		return ast.WalkContinue, nil
+	}
+
+	var css strings.Builder
+	for _, t := range themes {
+		css.WriteString(t.CSS)
+	}
+
+	// Apply the same transformations as the SVGs' own stylesheets.
+	transformers := r.SVGTransformers
+	if r.Sanitize {
+		transformers = append(transformers[:len(transformers):len(transformers)], SanitizeSVG)
+	}
+	sheet, err := transformStyleSheet(css.String(), transformers)
+	if err != nil {
+		return ast.WalkStop, fmt.Errorf("transform shared styles: %w", err)
+	}
+
+	writeStyleSheet(w, sheet)
+	return ast.WalkContinue, nil
+}
+
+// hasStyleBlock reports whether the document has a [StyleBlock].
+func hasStyleBlock(doc ast.Node) (found bool) {
+	if doc == nil {
+		return false
+	}
+
+	_ = ast.Walk(doc, func(node ast.Node, enter bool) (ast.WalkStatus, error) {
+		if _, ok := node.(*StyleBlock); ok {
+			found = true
+			return ast.WalkStop, nil
+		}
+		return ast.WalkContinue, nil
+	})
+	return found
+}
+
+// sharedStyles reports whether diagrams share a stylesheet.
+func (r *ServerRenderer) sharedStyles() bool {
+	return r.SharedStyles && r.Format == FormatSVG && r.Assets == nil
+}
+
+// theme returns the theme stylesheet of the given Block,
+// compiling it if necessary.
+// It returns nil if the Block failed to compile
+// or doesn't have a theme stylesheet.
+func (r *ServerRenderer) theme(src []byte, n *Block) *themeStyle {
+	if n.theme != nil {
+		return n.theme
+	}
+
+	result := r.result(src, n)
+	if result.Err != nil || result.Response == nil || len(result.Response.SVG) == 0 {
+		return nil
+	}
+
+	doc, err := ParseSVG(result.Response.SVG)
+	if err != nil {
+		return nil
+	}
+	n.theme = extractThemeStyle(doc, classDefNames(n.source(src)))
+	return n.theme
+}
 
-	// Normally, we won't hit this
-	// because Transformer won't add ScriptBlocks for ServerRenderer.
+// RenderScript renders [ScriptBlock] nodes.
+//
+// This renders scripts needed by PanZoom and Toolbar,
+// and the Mermaid script if a diagram in the document
+// fell back to client-side rendering.
+func (r *ServerRenderer) RenderScript(w util.BufWriter, src []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	// Normally, Transformer won't add ScriptBlocks for ServerRenderer
+	// unless one of the above is in use.
 	//
 	// Guard against the possibility that the document used a different
 	// transformer.
-	reg.Register(ScriptKind, func(util.BufWriter, []byte, ast.Node, bool) (ast.WalkStatus, error) {
-		return ast.WalkContinue, nil // no-op
-	})
+	if r.Fallback != nil && hasFallback(node.OwnerDocument()) {
+		// Fallback handles PanZoom and Toolbar for us.
+		fallback := *r.Fallback
+		fallback.PanZoom = fallback.PanZoom || r.PanZoom
+		fallback.Toolbar = fallback.Toolbar || r.Toolbar
+		return fallback.RenderScript(w, src, node, entering)
+	}
+
+	if entering {
+		return ast.WalkContinue, nil
+	}
+
+	if r.PanZoom {
+		selector := classSelector(_defaultContainerClass)
+		if r.Fallback != nil {
+			selector = classSelector(r.Fallback.containerClass())
+		}
+		if err := writePanZoomAssets(w, panZoomOptions{Selector: selector}); err != nil {
+			return ast.WalkStop, err
+		}
+	}
+	if r.Toolbar {
+		writeToolbarAssets(w)
+	}
+	return ast.WalkContinue, nil
 }
 
-// Render renders [Block] nodes.
-func (r *ServerRenderer) Render(w util.BufWriter, src []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
-	compiler := r.Compiler
-	if compiler == nil {
-		compiler = new(CLICompiler)
+// hasFallback reports whether any Block in the document
+// was rendered client-side.
+func hasFallback(doc ast.Node) (found bool) {
+	if doc == nil {
+		return false
 	}
 
+	_ = ast.Walk(doc, func(node ast.Node, enter bool) (ast.WalkStatus, error) {
+		if b, ok := node.(*Block); ok && enter && b.fallback {
+			found = true
+			return ast.WalkStop, nil
+		}
+		return ast.WalkContinue, nil
+	})
+	return found
+}
+
+func (r *ServerRenderer) container() *container {
 	tag := r.ContainerTag
 	if len(tag) == 0 {
 		tag = "div"
 	}
 
+	class := r.ContainerClass
+	if len(class) == 0 {
+		class = _defaultContainerClass
+	}
+
+	return &container{
+		Tag:        tag,
+		Class:      class + " " + _renderedClass,
+		Attributes: r.ContainerAttributes,
+	}
+}
+
+// Render renders [Block] nodes.
+func (r *ServerRenderer) Render(w util.BufWriter, src []byte, node ast.Node, entering bool) (ast.WalkStatus, error) {
+	c := r.container()
 	n := node.(*Block)
 	if !entering {
-		_, _ = w.WriteString("</")
-		template.HTMLEscape(w, []byte(tag))
-		_, _ = w.WriteString(">")
+		if n.fallback {
+			return r.Fallback.Render(w, src, node, entering)
+		}
+		if n.failed {
+			return ast.WalkContinue, nil
+		}
+
+		if r.PanZoom {
+			_, _ = w.WriteString(_panZoomClose)
+		}
+		c.Close(w)
+		if r.Toolbar {
+			writeToolbarClose(w, n.source(src))
+		}
 		return ast.WalkContinue, nil
 	}
-	_, _ = w.WriteString("<")
-	template.HTMLEscape(w, []byte(tag))
-	_, _ = w.WriteString(` class="mermaid">`)
 
-	var buff bytes.Buffer
-	lines := n.Lines()
-	for i := 0; i < lines.Len(); i++ {
-		line := lines.At(i)
-		buff.Write(line.Value(src))
+	if r.Format != FormatSVG && r.Format != FormatPNG {
+		return ast.WalkStop, fmt.Errorf("format %v cannot be rendered into HTML", r.Format)
 	}
 
-	if buff.Len() == 0 {
-		return ast.WalkContinue, nil
+	// Report invalid sizing attributes
+	// even if the diagram fails to compile.
+	if _, err := blockSizing(n, r.Sizing); err != nil {
+		reportDiagnostic(r.Diagnostics, n, src, err.Error())
 	}
 
-	res, err := compiler.Compile(context.Background(), &CompileRequest{
-		Source: buff.String(),
-	})
-	if err != nil {
-		return ast.WalkContinue, fmt.Errorf("generate svg: %w", err)
+	res := new(CompileResponse)
+	if source := n.source(src); len(source) > 0 {
+		result := r.result(src, n)
+		if err := result.Err; err != nil {
+			// The conversion was canceled.
+			// Don't bother with the remaining diagrams.
+			if ctxErr := n.context().Err(); ctxErr != nil {
+				return ast.WalkStop, fmt.Errorf("generate svg: %w", ctxErr)
+			}
+
+			var limitErr *LimitError
+			if r.Fallback != nil && !errors.As(err, &limitErr) {
+				n.fallback = true
+				return r.Fallback.Render(w, src, node, entering)
+			}
+
+			return r.handleError(w, n, source, err)
+		}
+		res = result.Response
+	}
+
+	for _, warning := range res.Warnings {
+		reportDiagnostic(r.Diagnostics, n, src, "mermaid: "+warning)
+	}
+	if r.Accessible && len(blockAccessibility(n, src).Name()) == 0 {
+		reportDiagnostic(r.Diagnostics, n, src,
+			"diagram has no accessible name: add accTitle or a title attribute")
+	}
+
+	svg := res.SVG
+	if transformers := r.svgTransformers(n, src); len(svg) > 0 && len(transformers) > 0 {
+		var err error
+		svg, err = transformSVG(svg, transformers)
+		if err != nil {
+			return r.handleError(w, n, n.source(src), fmt.Errorf("transform svg: %w", err))
+		}
+	}
+
+	if r.Toolbar {
+		_, _ = w.WriteString(_toolbarOpen)
+	}
+	c.Open(w, n, ` data-processed="true"`)
+	if r.PanZoom {
+		_, _ = w.WriteString(_panZoomOpen)
+	}
+
+	var img *diagramImage
+	switch {
+	case r.Format == FormatPNG && len(res.Data) > 0:
+		img = pngImage(res.Data, r.Scale)
+	case r.Assets != nil && len(svg) > 0:
+		img = svgImage(svg)
+	default:
+		_, err := w.WriteString(svg)
+		return ast.WalkContinue, err
+	}
+	if res.Width > 0 && res.Height > 0 {
+		// The compiler's size is in CSS pixels,
+		// and doesn't depend on the image format.
+		img.Width = int(math.Round(res.Width))
+		img.Height = int(math.Round(res.Height))
+	}
+
+	if err := r.writeImage(w, n, src, img); err != nil {
+		return ast.WalkStop, err
+	}
+	return ast.WalkContinue, nil
+}
+
+// handleError passes a failure to compile or transform a diagram
+// to the ErrorHandler, and marks the block as failed.
+func (r *ServerRenderer) handleError(w util.BufWriter, n *Block, source []byte, err error) (ast.WalkStatus, error) {
+	handler := r.ErrorHandler
+	if handler == nil {
+		handler = FailOnError
 	}
 
-	_, err = w.WriteString(res.SVG)
+	n.failed = true
+	err = handler.HandleError(w, &CompileError{
+		Source:    string(source),
+		Err:       err,
+		collected: n.errs,
+	})
 	return ast.WalkContinue, err
 }
+
+// writeImage writes an <img> tag for the given image.
+//
+// If Assets is set, the image is written to it
+// and referenced by URL.
+// Otherwise, it's embedded as a data URI.
+func (r *ServerRenderer) writeImage(w util.BufWriter, n *Block, src []byte, img *diagramImage) error {
+	var url string
+	if r.Assets != nil {
+		name := assetName(img.Data, img.Ext)
+		if err := r.Assets.WriteAsset(n.context(), name, img.Data); err != nil {
+			return fmt.Errorf("write asset %v: %w", name, err)
+		}
+
+		prefix := r.AssetURLPrefix
+		if len(prefix) == 0 {
+			prefix = _defaultAssetURLPrefix
+		}
+		url = prefix + name
+	} else {
+		url = "data:" + img.MIME + ";base64," + base64.StdEncoding.EncodeToString(img.Data)
+	}
+
+	alt := blockAccessibility(n, src).AltText()
+
+	width, height := img.Width, img.Height
+	var style string
+	sizing := r.sizing(n)
+	switch sizing.Mode {
+	case SizeFixed:
+		if width > 0 && height > 0 {
+			height = int(float64(height*sizing.Width)/float64(width) + 0.5)
+		} else {
+			// Unknown aspect ratio. Let the browser pick the height.
+			height = 0
+		}
+		width = sizing.Width
+	case SizeFill:
+		width, height = 0, 0
+		style = "width: 100%; height: auto;"
+	case SizeMaxWidth:
+		width, height = 0, 0
+		style = fmt.Sprintf("width: 100%%; max-width: %dpx; height: auto;", sizing.Width)
+	}
+
+	_, _ = w.WriteString("<img")
+	writeAttribute(w, []byte("src"), url)
+	writeAttribute(w, []byte("alt"), alt)
+	// Don't write only one of intrinsic width or height.
+	// A fixed width is written even if the height is unknown.
+	if width > 0 && (height > 0 || sizing.Mode == SizeFixed) {
+		writeAttribute(w, []byte("width"), strconv.Itoa(width))
+	}
+	if width > 0 && height > 0 {
+		writeAttribute(w, []byte("height"), strconv.Itoa(height))
+	}
+	if len(style) > 0 {
+		writeAttribute(w, []byte("style"), style)
+	}
+	_, _ = w.WriteString(">")
+	return nil
+}
+
+// sizing returns the Sizing for the given Block.
+// Invalid fence attributes are ignored.
+func (r *ServerRenderer) sizing(n *Block) Sizing {
+	s, _ := blockSizing(n, r.Sizing)
+	return s.normalize()
+}
+
+// svgTransformers returns the SVGTransformers
+// to apply to the SVG for the given Block.
+func (r *ServerRenderer) svgTransformers(n *Block, src []byte) []SVGTransformer {
+	sizing := r.sizing(n)
+	// Without a StyleBlock, the theme would be lost.
+	sharedStyles := r.sharedStyles() && hasStyleBlock(n.OwnerDocument())
+	if !r.UniqueIDs && !r.Accessible && !r.Sanitize && !r.Deterministic && !sharedStyles && sizing.Mode == SizeAuto {
+		return r.SVGTransformers
+	}
+
+	transformers := make([]SVGTransformer, 0, len(r.SVGTransformers)+6)
+	if sharedStyles {
+		// Before UniqueIDs so that the theme doesn't depend on the ID.
+		transformers = append(transformers, SVGTransformerFunc(func(doc *SVGDocument) error {
+			n.theme = extractThemeStyle(doc, classDefNames(n.source(src)))
+			return nil
+		}))
+	}
+	if r.Deterministic {
+		transformers = append(transformers, SVGTransformerFunc(normalizeSVG))
+	}
+	if r.UniqueIDs {
+		transformers = append(transformers, SVGTransformerFunc(func(doc *SVGDocument) error {
+			prefixSVGIDs(doc, svgID(n, src))
+			return nil
+		}))
+	}
+	if r.Accessible {
+		transformers = append(transformers, SVGTransformerFunc(func(doc *SVGDocument) error {
+			makeSVGAccessible(doc, blockAccessibility(n, src), svgID(n, src))
+			return nil
+		}))
+	}
+	if sizing.Mode != SizeAuto {
+		transformers = append(transformers, SVGTransformerFunc(func(doc *SVGDocument) error {
+			resizeSVG(doc, sizing)
+			return nil
+		}))
+	}
+	transformers = append(transformers, r.SVGTransformers...)
+	if r.Sanitize {
+		transformers = append(transformers, SanitizeSVG)
+	}
+	return transformers
+}
+
+// svgID returns the prefix for IDs in the given Block's SVG.
+//
+// The first call for a document assigns prefixes
+// to all Blocks in that document.
+func svgID(n *Block, src []byte) string {
+	if len(n.svgID) > 0 {
+		return n.svgID
+	}
+
+	blocks := []*Block{n}
+	if doc := n.OwnerDocument(); doc != nil {
+		blocks = blocks[:0]
+		_ = ast.Walk(doc, func(node ast.Node, enter bool) (ast.WalkStatus, error) {
+			if b, ok := node.(*Block); ok && enter {
+				blocks = append(blocks, b)
+			}
+			return ast.WalkContinue, nil
+		})
+	}
+
+	for i, prefix := range svgIDPrefixes(blockIDs(blocks, src)) {
+		blocks[i].svgID = prefix
+	}
+	return n.svgID
+}
+
+// compileResult is the outcome of compiling a [Block].
+type compileResult struct {
+	Response *CompileResponse
+	Err      error
+}
+
+// result returns the result of compiling the given Block.
+//
+// If Concurrency allows it, the first call for a document
+// compiles all diagrams in that document concurrently.
+func (r *ServerRenderer) result(src []byte, n *Block) *compileResult {
+	if n.result == nil && !n.counted && r.MaxDiagrams > 0 {
+		if doc := n.OwnerDocument(); doc != nil {
+			r.limitDiagrams(doc)
+		}
+	}
+
+	if n.result == nil && r.Concurrency > 1 {
+		if doc := n.OwnerDocument(); doc != nil {
+			r.precompile(src, doc)
+		}
+	}
+
+	// The Block may not be part of a document.
+	if n.result == nil {
+		n.result = r.compile(src, n)
+	}
+	return n.result
+}
+
+// limitDiagrams fails all Blocks in the document
+// past the first MaxDiagrams with a LimitError.
+func (r *ServerRenderer) limitDiagrams(doc ast.Node) {
+	var count int
+	_ = ast.Walk(doc, func(node ast.Node, enter bool) (ast.WalkStatus, error) {
+		b, ok := node.(*Block)
+		if !ok || !enter {
+			return ast.WalkContinue, nil
+		}
+
+		b.counted = true
+		if b.Lines().Len() == 0 {
+			return ast.WalkContinue, nil
+		}
+
+		count++
+		if count > r.MaxDiagrams && b.result == nil {
+			b.result = &compileResult{Err: &LimitError{
+				Limit: LimitDiagrams,
+				Max:   int64(r.MaxDiagrams),
+			}}
+		}
+		return ast.WalkContinue, nil
+	})
+}
+
+// precompile compiles all Blocks in the document
+// that haven't been compiled yet,
+// running up to Concurrency compilations at a time.
+func (r *ServerRenderer) precompile(src []byte, doc ast.Node) {
+	var blocks []*Block
+	_ = ast.Walk(doc, func(node ast.Node, enter bool) (ast.WalkStatus, error) {
+		if b, ok := node.(*Block); ok && enter && b.result == nil && b.Lines().Len() > 0 {
+			blocks = append(blocks, b)
+		}
+		return ast.WalkContinue, nil
+	})
+
+	if r.UniqueIDs && len(blocks) > 0 {
+		// Assign IDs to all Blocks before compiling them concurrently.
+		_ = svgID(blocks[0], src)
+	}
+
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, r.Concurrency)
+	for _, b := range blocks {
+		sem <- struct{}{}
+
+		// Canceled. Don't start the remaining compilations.
+		if err := b.context().Err(); err != nil {
+			b.result = &compileResult{Err: err}
+			<-sem
+			continue
+		}
+
+		wg.Add(1)
+		go func(b *Block) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+
+			b.result = r.compile(src, b)
+		}(b)
+	}
+	wg.Wait()
+}
+
+// compile compiles the given Block with the configured Compiler.
+func (r *ServerRenderer) compile(src []byte, n *Block) *compileResult {
+	compiler := r.Compiler
+	if compiler == nil {
+		compiler = new(CLICompiler)
+	}
+
+	source := n.source(src)
+	if r.MaxSourceSize > 0 && len(source) > r.MaxSourceSize {
+		return &compileResult{Err: &LimitError{
+			Limit: LimitSourceSize,
+			Max:   int64(r.MaxSourceSize),
+		}}
+	}
+
+	parent := n.context()
+	ctx := parent
+	if r.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+		defer cancel()
+	}
+
+	req := &CompileRequest{
+		Source:      string(source),
+		Format:      r.Format,
+		Scale:       r.Scale,
+		Theme:       r.Theme,
+		Config:      r.MermaidConfig,
+		Background:  r.Background,
+		MaxTextSize: r.MaxSourceSize,
+		MaxEdges:    r.MaxEdges,
+	}
+	if r.Deterministic {
+		req.DeterministicIDSeed = deterministicSeed(source)
+	}
+	if r.UniqueIDs {
+		// Compile with the ID that prefixSVGIDs will assign
+		// so that the compiled SVG already refers to it.
+		req.ID = svgID(n, src)
+	}
+	if s := r.sizing(n); s.Mode == SizeFixed {
+		req.Width = s.Width
+	}
+
+	res, err := compiler.Compile(ctx, req)
+	switch {
+	case err == nil:
+	case r.Timeout > 0 && errors.Is(ctx.Err(), context.DeadlineExceeded) && parent.Err() == nil:
+		// Our timeout, not the caller's.
+		// Check the context rather than the error:
+		// compilers may report the timeout differently,
+		// for example, as the exit status of a killed process.
+		err = &LimitError{Limit: LimitTimeout, Max: int64(r.Timeout), Err: err}
+	case r.MaxEdges > 0 && strings.Contains(err.Error(), _edgeLimitMessage):
+		err = &LimitError{Limit: LimitEdges, Max: int64(r.MaxEdges), Err: err}
+	}
+	return &compileResult{Response: res, Err: err}
+}
+
+// _edgeLimitMessage is part of the error message Mermaid reports
+// for diagrams with more than maxEdges edges.
+const _edgeLimitMessage = "Edge limit exceeded"
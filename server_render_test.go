@@ -3,11 +3,28 @@ package mermaid
 import (
 	"bytes"
 	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/parser"
+	"github.com/yuin/goldmark/renderer"
 	"github.com/yuin/goldmark/text"
+	"github.com/yuin/goldmark/util"
+	"go.abhg.dev/goldmark/mermaid/internal/exectest"
 )
 
 func TestServerRenderer_Simple(t *testing.T) {
@@ -29,7 +46,7 @@ func TestServerRenderer_Simple(t *testing.T) {
 
 	var buff bytes.Buffer
 	require.NoError(t, r.Render(&buff, reader.Source(), give), "Render")
-	assert.Equal(t, `<div class="mermaid"><svg>A -> B</svg></div>`, buff.String())
+	assert.Equal(t, `<div class="mermaid mermaid-rendered" data-processed="true"><svg>A -> B</svg></div>`, buff.String())
 }
 
 func TestServerRenderer_ContainerTag(t *testing.T) {
@@ -52,7 +69,7 @@ func TestServerRenderer_ContainerTag(t *testing.T) {
 
 	var buff bytes.Buffer
 	require.NoError(t, r.Render(&buff, reader.Source(), give), "Render")
-	assert.Equal(t, `<pre class="mermaid"><svg>A -> B</svg></pre>`, buff.String())
+	assert.Equal(t, `<pre class="mermaid mermaid-rendered" data-processed="true"><svg>A -> B</svg></pre>`, buff.String())
 }
 
 func TestServerRenderer_Empty(t *testing.T) {
@@ -73,7 +90,7 @@ func TestServerRenderer_Empty(t *testing.T) {
 
 	var buff bytes.Buffer
 	require.NoError(t, r.Render(&buff, reader.Source(), give), "Render")
-	assert.Equal(t, `<div class="mermaid"></div>`, buff.String())
+	assert.Equal(t, `<div class="mermaid mermaid-rendered" data-processed="true"></div>`, buff.String())
 }
 
 func TestServerRenderer_ScriptKindNoop(t *testing.T) {
@@ -97,6 +114,125 @@ func TestServerRenderer_ScriptKindNoop(t *testing.T) {
 	assert.Empty(t, buff.String())
 }
 
+func TestServerRenderer_Fallback(t *testing.T) {
+	t.Parallel()
+
+	compiler := compilerStub{
+		CompileF: func(_ context.Context, req *CompileRequest) (*CompileResponse, error) {
+			if strings.Contains(req.Source, "bad") {
+				return nil, errors.New("great sadness")
+			}
+			return &CompileResponse{
+				SVG: "<svg>" + strings.TrimSpace(req.Source) + "</svg>",
+			}, nil
+		},
+	}
+
+	md := goldmark.New(
+		goldmark.WithParserOptions(
+			parser.WithASTTransformers(
+				util.Prioritized(&Transformer{}, 100),
+			),
+		),
+		goldmark.WithRendererOptions(
+			renderer.WithNodeRenderers(
+				util.Prioritized(&ServerRenderer{
+					Compiler: &compiler,
+					Fallback: &ClientRenderer{MermaidURL: "mermaid.js"},
+				}, 100),
+			),
+		),
+	)
+
+	t.Run("no fallback", func(t *testing.T) {
+		t.Parallel()
+
+		var buff bytes.Buffer
+		require.NoError(t, md.Convert([]byte(unlines(
+			"```mermaid",
+			"good",
+			"```",
+		)), &buff))
+		assert.Equal(t, `<div class="mermaid mermaid-rendered" data-processed="true"><svg>good</svg></div>`, buff.String())
+	})
+
+	t.Run("fallback", func(t *testing.T) {
+		t.Parallel()
+
+		var buff bytes.Buffer
+		require.NoError(t, md.Convert([]byte(unlines(
+			"```mermaid",
+			"good",
+			"```",
+			"",
+			"```mermaid",
+			"bad",
+			"```",
+		)), &buff))
+		assert.Equal(t,
+			`<div class="mermaid mermaid-rendered" data-processed="true"><svg>good</svg></div>`+
+				"<pre class=\"mermaid\">bad\n</pre>"+
+				`<script src="mermaid.js"></script>`+
+				`<script>mermaid.initialize({"startOnLoad":true});</script>`,
+			buff.String())
+	})
+}
+
+func TestServerRenderer_PanZoom(t *testing.T) {
+	t.Parallel()
+
+	compiler := compilerStub{
+		CompileF: func(_ context.Context, req *CompileRequest) (*CompileResponse, error) {
+			return &CompileResponse{
+				SVG: "<svg>" + strings.TrimSpace(req.Source) + "</svg>",
+			}, nil
+		},
+	}
+
+	md := goldmark.New(
+		goldmark.WithExtensions(&Extender{
+			RenderMode: RenderModeServer,
+			Compiler:   &compiler,
+			PanZoom:    true,
+		}),
+	)
+
+	var buff bytes.Buffer
+	require.NoError(t, md.Convert([]byte(unlines(
+		"```mermaid",
+		"A -> B",
+		"```",
+	)), &buff))
+
+	got := buff.String()
+	assert.Contains(t, got,
+		`<div class="mermaid mermaid-rendered" data-processed="true">`+_panZoomOpen+`<svg>A -> B</svg>`+_panZoomClose+`</div>`)
+	assert.Equal(t, 1, strings.Count(got, "<style>"+_panZoomCSS+"</style>"),
+		"pan/zoom assets must be included exactly once")
+	assert.Contains(t, got, `({"selector":".mermaid"});</script>`)
+	assert.NotContains(t, got, "mermaid.initialize")
+}
+
+func TestServerRenderer_Error(t *testing.T) {
+	t.Parallel()
+
+	compiler := compilerStub{
+		CompileF: func(context.Context, *CompileRequest) (*CompileResponse, error) {
+			return nil, errors.New("great sadness")
+		},
+	}
+
+	r := buildNodeRenderer(&ServerRenderer{
+		Compiler: &compiler,
+	})
+	reader := text.NewReader([]byte(`A -> B`))
+	give := blockFromReader(reader)
+
+	var buff bytes.Buffer
+	err := r.Render(&buff, reader.Source(), give)
+	assert.ErrorContains(t, err, "generate svg: great sadness")
+}
+
 type compilerStub struct {
 	CompileF func(context.Context, *CompileRequest) (*CompileResponse, error)
 }
@@ -106,3 +242,1638 @@ var _ Compiler = (*compilerStub)(nil)
 func (c *compilerStub) Compile(ctx context.Context, req *CompileRequest) (*CompileResponse, error) {
 	return c.CompileF(ctx, req)
 }
+
+func TestServerRenderer_ContainerAttributes(t *testing.T) {
+	t.Parallel()
+
+	compiler := compilerStub{
+		CompileF: func(_ context.Context, req *CompileRequest) (*CompileResponse, error) {
+			return &CompileResponse{
+				SVG: "<svg>" + strings.TrimSpace(req.Source) + "</svg>",
+			}, nil
+		},
+	}
+
+	md := goldmark.New(
+		goldmark.WithExtensions(&Extender{
+			RenderMode:          RenderModeServer,
+			Compiler:            &compiler,
+			ContainerClass:      "diagram",
+			ContainerAttributes: map[string]string{"data-kind": "mermaid"},
+			GenerateIDs:         true,
+		}),
+	)
+
+	var buff bytes.Buffer
+	require.NoError(t, md.Convert([]byte(unlines(
+		"```mermaid {.wide}",
+		"foo",
+		"```",
+		"",
+		"```mermaid {#named}",
+		"bar",
+		"```",
+	)), &buff))
+	assert.Equal(t,
+		`<div id="mermaid-b5bb9d80" class="diagram mermaid-rendered wide" data-kind="mermaid" data-processed="true"><svg>foo</svg></div>`+
+			`<div id="named" class="diagram mermaid-rendered" data-kind="mermaid" data-processed="true"><svg>bar</svg></div>`,
+		buff.String())
+}
+
+func TestServerRenderer_Toolbar(t *testing.T) {
+	t.Parallel()
+
+	compiler := compilerStub{
+		CompileF: func(context.Context, *CompileRequest) (*CompileResponse, error) {
+			return &CompileResponse{SVG: "<svg></svg>"}, nil
+		},
+	}
+
+	md := goldmark.New(
+		goldmark.WithExtensions(&Extender{
+			RenderMode: RenderModeServer,
+			Compiler:   &compiler,
+			Toolbar:    true,
+		}),
+	)
+
+	var buff bytes.Buffer
+	require.NoError(t, md.Convert([]byte(unlines(
+		"```mermaid",
+		"A --> B",
+		"```",
+		"",
+		"```mermaid",
+		"C --> D",
+		"```",
+	)), &buff))
+
+	got := buff.String()
+	assert.Contains(t, got,
+		_toolbarOpen+
+			`<div class="mermaid mermaid-rendered" data-processed="true"><svg></svg></div>`+
+			`<pre class="mermaid-source" hidden>A --&gt; B`+"\n"+`</pre></div>`)
+	assert.Equal(t, 2, strings.Count(got, _toolbarOpen))
+	assert.Equal(t, 1, strings.Count(got, "<style>"+_toolbarCSS+"</style>"),
+		"toolbar assets must be included exactly once")
+}
+
+func TestServerRenderer_Concurrency(t *testing.T) {
+	t.Parallel()
+
+	const concurrency = 3
+
+	var (
+		mu       sync.Mutex
+		inFlight int
+		maxSeen  int
+
+		// Closed when the maximum number of compilations
+		// are running at the same time.
+		full     = make(chan struct{})
+		fullOnce sync.Once
+	)
+	compiler := compilerStub{
+		CompileF: func(_ context.Context, req *CompileRequest) (*CompileResponse, error) {
+			mu.Lock()
+			inFlight++
+			if inFlight > maxSeen {
+				maxSeen = inFlight
+			}
+			if inFlight == concurrency {
+				fullOnce.Do(func() { close(full) })
+			}
+			mu.Unlock()
+
+			defer func() {
+				mu.Lock()
+				inFlight--
+				mu.Unlock()
+			}()
+
+			select {
+			case <-full:
+			case <-time.After(5 * time.Second):
+				return nil, errors.New("compilations did not run concurrently")
+			}
+
+			source := strings.TrimSpace(req.Source)
+			if source == "bad" {
+				return nil, errors.New("great sadness")
+			}
+			return &CompileResponse{SVG: "<svg>" + source + "</svg>"}, nil
+		},
+	}
+
+	md := goldmark.New(
+		goldmark.WithExtensions(&Extender{
+			RenderMode:   RenderModeServer,
+			Compiler:     &compiler,
+			Concurrency:  concurrency,
+			ErrorHandler: RenderErrorBox,
+		}),
+	)
+
+	var (
+		src  []string
+		want strings.Builder
+	)
+	for _, s := range []string{"a", "b", "bad", "c", "d", "e"} {
+		src = append(src, "```mermaid", s, "```", "")
+		if s == "bad" {
+			want.WriteString(`<div class="mermaid-error" role="alert"><p>Unable to render diagram:</p><pre>great sadness</pre></div>`)
+		} else {
+			want.WriteString(`<div class="mermaid mermaid-rendered" data-processed="true"><svg>` + s + `</svg></div>`)
+		}
+	}
+
+	var buff bytes.Buffer
+	require.NoError(t, md.Convert([]byte(unlines(src...)), &buff))
+	assert.Equal(t, want.String(), buff.String())
+	assert.Equal(t, concurrency, maxSeen)
+}
+
+func TestServerRenderer_Timeout(t *testing.T) {
+	t.Parallel()
+
+	compiler := compilerStub{
+		CompileF: func(ctx context.Context, req *CompileRequest) (*CompileResponse, error) {
+			if strings.TrimSpace(req.Source) == "fast" {
+				return &CompileResponse{SVG: "<svg>fast</svg>"}, nil
+			}
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	}
+
+	md := goldmark.New(
+		goldmark.WithExtensions(&Extender{
+			RenderMode:   RenderModeServer,
+			Compiler:     &compiler,
+			Timeout:      time.Millisecond,
+			ErrorHandler: RenderErrorBox,
+		}),
+	)
+
+	var buff bytes.Buffer
+	require.NoError(t, md.Convert([]byte(unlines(
+		"```mermaid",
+		"slow",
+		"```",
+		"",
+		"```mermaid",
+		"fast",
+		"```",
+	)), &buff))
+	assert.Equal(t,
+		`<div class="mermaid-error" role="alert"><p>Unable to render diagram:</p><pre>diagram took longer than 1ms to compile: context deadline exceeded</pre></div>`+
+			`<div class="mermaid mermaid-rendered" data-processed="true"><svg>fast</svg></div>`,
+		buff.String())
+}
+
+func TestServerRenderer_limits(t *testing.T) {
+	t.Parallel()
+
+	compiler := compilerStub{
+		CompileF: func(ctx context.Context, req *CompileRequest) (*CompileResponse, error) {
+			switch src := strings.TrimSpace(req.Source); src {
+			case "slow":
+				<-ctx.Done()
+				return nil, ctx.Err()
+			case "edges":
+				return nil, errors.New("Edge limit exceeded. 3 edges found, but the limit is 2.")
+			default:
+				return &CompileResponse{SVG: "<svg>" + src + "</svg>"}, nil
+			}
+		},
+	}
+
+	tests := []struct {
+		name        string
+		concurrency int
+	}{
+		{name: "sequential"},
+		{name: "concurrent", concurrency: 4},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			errs := &ErrorCollector{Handler: RenderErrorBox}
+			md := goldmark.New(
+				goldmark.WithExtensions(&Extender{
+					RenderMode:    RenderModeServer,
+					Compiler:      &compiler,
+					Concurrency:   tt.concurrency,
+					Timeout:       time.Millisecond,
+					MaxSourceSize: 10,
+					MaxDiagrams:   4,
+					MaxEdges:      2,
+					ErrorHandler:  errs,
+				}),
+			)
+
+			var buff bytes.Buffer
+			require.NoError(t, md.Convert([]byte(unlines(
+				"```mermaid",
+				"ok",
+				"```",
+				"",
+				"```mermaid",
+				"slow",
+				"```",
+				"",
+				"```mermaid",
+				"too long to render",
+				"```",
+				"",
+				"```mermaid",
+				"```",
+				"",
+				"```mermaid",
+				"edges",
+				"```",
+				"",
+				"```mermaid",
+				"extra",
+				"```",
+			)), &buff))
+
+			got := errs.Errors()
+			require.Len(t, got, 4)
+
+			wantLimits := []struct {
+				limit Limit
+				max   int64
+				msg   string
+			}{
+				{
+					LimitTimeout, int64(time.Millisecond),
+					"diagram took longer than 1ms to compile: context deadline exceeded",
+				},
+				{LimitSourceSize, 10, "diagram source exceeds 10 bytes"},
+				{
+					LimitEdges, 2,
+					"diagram exceeds 2 edges: Edge limit exceeded. 3 edges found, but the limit is 2.",
+				},
+				{LimitDiagrams, 4, "document exceeds 4 diagrams"},
+			}
+			for i, want := range wantLimits {
+				var limitErr *LimitError
+				require.ErrorAs(t, got[i], &limitErr)
+				assert.Equal(t, want.limit, limitErr.Limit)
+				assert.Equal(t, want.max, limitErr.Max)
+				assert.EqualError(t, got[i], want.msg)
+			}
+			assert.ErrorIs(t, got[0], context.DeadlineExceeded)
+
+			assert.Contains(t, buff.String(), "<svg>ok</svg>")
+			assert.NotContains(t, buff.String(), "<svg>extra</svg>")
+		})
+	}
+}
+
+func TestServerRenderer_limits_request(t *testing.T) {
+	t.Parallel()
+
+	var got *CompileRequest
+	compiler := compilerStub{
+		CompileF: func(_ context.Context, req *CompileRequest) (*CompileResponse, error) {
+			got = req
+			return &CompileResponse{SVG: "<svg></svg>"}, nil
+		},
+	}
+
+	md := goldmark.New(
+		goldmark.WithExtensions(&Extender{
+			RenderMode:    RenderModeServer,
+			Compiler:      &compiler,
+			MaxSourceSize: 1000,
+			MaxEdges:      50,
+		}),
+	)
+
+	var buff bytes.Buffer
+	require.NoError(t, md.Convert([]byte(unlines(
+		"```mermaid",
+		"graph",
+		"```",
+	)), &buff))
+
+	require.NotNil(t, got)
+	assert.Equal(t, 1000, got.MaxTextSize)
+	assert.Equal(t, 50, got.MaxEdges)
+}
+
+// Diagrams that exceed a limit are handled by the ErrorHandler
+// instead of falling back to client-side rendering.
+// TestServerRenderer_Timeout_CLI verifies that timeouts are reported
+// as LimitErrors when mmdc is killed instead of returning the context error.
+func TestServerRenderer_Timeout_CLI(t *testing.T) {
+	t.Parallel()
+
+	mmdc := exectest.Act(t, func() {
+		time.Sleep(time.Minute)
+	})
+
+	errs := &ErrorCollector{Handler: RenderErrorBox}
+	md := goldmark.New(
+		goldmark.WithExtensions(&Extender{
+			RenderMode:   RenderModeHybrid,
+			Compiler:     &CLICompiler{CLI: mmdc},
+			Timeout:      100 * time.Millisecond,
+			ErrorHandler: errs,
+			MermaidURL:   "mermaid.js",
+		}),
+	)
+
+	var buff bytes.Buffer
+	require.NoError(t, md.Convert([]byte(unlines(
+		"```mermaid",
+		"graph",
+		"```",
+	)), &buff))
+
+	// Not rendered client-side.
+	assert.NotContains(t, buff.String(), `<pre class="mermaid">`)
+	assert.Contains(t, buff.String(), "diagram took longer than 100ms to compile")
+
+	require.Len(t, errs.Errors(), 1)
+	var limitErr *LimitError
+	require.ErrorAs(t, errs.Errors()[0], &limitErr)
+	assert.Equal(t, LimitTimeout, limitErr.Limit)
+	assert.ErrorIs(t, limitErr, context.DeadlineExceeded)
+}
+
+func TestServerRenderer_limits_noFallback(t *testing.T) {
+	t.Parallel()
+
+	compiler := compilerStub{
+		CompileF: func(context.Context, *CompileRequest) (*CompileResponse, error) {
+			return nil, errors.New("great sadness")
+		},
+	}
+
+	md := goldmark.New(
+		goldmark.WithExtensions(&Extender{
+			RenderMode:    RenderModeHybrid,
+			Compiler:      &compiler,
+			MaxSourceSize: 10,
+			ErrorHandler:  RenderErrorBox,
+			MermaidURL:    "mermaid.js",
+		}),
+	)
+
+	var buff bytes.Buffer
+	require.NoError(t, md.Convert([]byte(unlines(
+		"```mermaid",
+		"broken",
+		"```",
+		"",
+		"```mermaid",
+		"too long to render",
+		"```",
+	)), &buff))
+
+	assert.Equal(t,
+		`<pre class="mermaid">broken`+"\n"+`</pre>`+
+			`<div class="mermaid-error" role="alert"><p>Unable to render diagram:</p>`+
+			`<pre>diagram source exceeds 10 bytes</pre></div>`+
+			`<script src="mermaid.js"></script><script>mermaid.initialize({"startOnLoad":true});</script>`,
+		buff.String())
+}
+
+func TestServerRenderer_Canceled(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name        string
+		concurrency int
+	}{
+		{name: "sequential"},
+		{name: "concurrent", concurrency: 2},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			var calls atomic.Int32
+			compiler := compilerStub{
+				CompileF: func(ctx context.Context, _ *CompileRequest) (*CompileResponse, error) {
+					calls.Add(1)
+					cancel() // the conversion is aborted mid-way
+					<-ctx.Done()
+					return nil, ctx.Err()
+				},
+			}
+
+			md := goldmark.New(
+				goldmark.WithExtensions(&Extender{
+					RenderMode:   RenderModeServer,
+					Compiler:     &compiler,
+					Concurrency:  tt.concurrency,
+					ErrorHandler: RenderErrorBox,
+				}),
+			)
+
+			var src []string
+			for i := 0; i < 5; i++ {
+				src = append(src, "```mermaid", "graph", "```", "")
+			}
+
+			var buff bytes.Buffer
+			err := ConvertContext(ctx, md, []byte(unlines(src...)), &buff)
+			assert.ErrorIs(t, err, context.Canceled)
+			assert.NotContains(t, buff.String(), "mermaid-error",
+				"cancellation must not be handled by ErrorHandler")
+			assert.LessOrEqual(t, int(calls.Load()), max(tt.concurrency, 1),
+				"no compilations must start after cancellation")
+		})
+	}
+}
+
+func TestServerRenderer_Assets(t *testing.T) {
+	t.Parallel()
+
+	compiler := compilerStub{
+		CompileF: func(_ context.Context, req *CompileRequest) (*CompileResponse, error) {
+			return &CompileResponse{
+				SVG: `<svg viewBox="0 0 200 100">` + strings.TrimSpace(req.Source) + `</svg>`,
+			}, nil
+		},
+	}
+
+	tests := []struct {
+		name   string
+		prefix string
+		src    []string
+		want   string
+	}{
+		{
+			name: "default",
+			src:  []string{"graph"},
+			want: `<div class="mermaid mermaid-rendered" data-processed="true">` +
+				`<img src="/assets/mermaid/%v" alt="Mermaid diagram" width="200" height="100">` +
+				`</div>`,
+		},
+		{
+			name:   "prefix and title",
+			prefix: "https://cdn.example.com/d/",
+			src:    []string{"graph", "accTitle: Checkout & payment"},
+			want: `<div class="mermaid mermaid-rendered" data-processed="true">` +
+				`<img src="https://cdn.example.com/d/%v" alt="Checkout &amp; payment" width="200" height="100">` +
+				`</div>`,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			assets := new(AssetFS)
+			md := goldmark.New(
+				goldmark.WithExtensions(&Extender{
+					RenderMode:     RenderModeServer,
+					Compiler:       &compiler,
+					Assets:         assets,
+					AssetURLPrefix: tt.prefix,
+				}),
+			)
+
+			src := append([]string{"```mermaid"}, tt.src...)
+			src = append(src, "```")
+
+			var buff bytes.Buffer
+			require.NoError(t, md.Convert([]byte(unlines(src...)), &buff))
+
+			entries, err := assets.ReadDir(".")
+			require.NoError(t, err)
+			require.Len(t, entries, 1)
+			name := entries[0].Name()
+			assert.Regexp(t, `^[0-9a-f]{16}\.svg$`, name)
+			assert.Equal(t, fmt.Sprintf(tt.want, name), buff.String())
+
+			f, err := assets.Open(name)
+			require.NoError(t, err)
+			defer func() {
+				assert.NoError(t, f.Close())
+			}()
+			got, err := io.ReadAll(f)
+			require.NoError(t, err)
+			assert.Equal(t, `<svg viewBox="0 0 200 100">`+strings.TrimSpace(unlines(tt.src...))+`</svg>`, string(got))
+		})
+	}
+}
+
+func TestServerRenderer_AssetsError(t *testing.T) {
+	t.Parallel()
+
+	compiler := compilerStub{
+		CompileF: func(context.Context, *CompileRequest) (*CompileResponse, error) {
+			return &CompileResponse{SVG: "<svg></svg>"}, nil
+		},
+	}
+
+	md := goldmark.New(
+		goldmark.WithExtensions(&Extender{
+			RenderMode: RenderModeServer,
+			Compiler:   &compiler,
+			Assets:     new(DirAssetWriter),
+		}),
+	)
+
+	var buff bytes.Buffer
+	err := md.Convert([]byte(unlines(
+		"```mermaid",
+		"graph",
+		"```",
+	)), &buff)
+	assert.ErrorContains(t, err, "asset directory must be specified")
+}
+
+func TestServerRenderer_PNG(t *testing.T) {
+	t.Parallel()
+
+	// 400x200 image rendered at 2x.
+	var pngData bytes.Buffer
+	require.NoError(t, png.Encode(&pngData, image.NewGray(image.Rect(0, 0, 400, 200))))
+
+	var got *CompileRequest
+	compiler := compilerStub{
+		CompileF: func(_ context.Context, req *CompileRequest) (*CompileResponse, error) {
+			got = req
+			return &CompileResponse{Data: pngData.Bytes()}, nil
+		},
+	}
+
+	t.Run("data URI", func(t *testing.T) {
+		md := goldmark.New(
+			goldmark.WithExtensions(&Extender{
+				RenderMode: RenderModeServer,
+				Compiler:   &compiler,
+				Format:     FormatPNG,
+				Scale:      2,
+			}),
+		)
+
+		var buff bytes.Buffer
+		require.NoError(t, md.Convert([]byte(unlines(
+			"```mermaid",
+			"graph",
+			"```",
+		)), &buff))
+
+		require.NotNil(t, got)
+		assert.Equal(t, FormatPNG, got.Format)
+		assert.Equal(t, 2.0, got.Scale)
+
+		assert.Equal(t,
+			`<div class="mermaid mermaid-rendered" data-processed="true">`+
+				`<img src="data:image/png;base64,`+base64.StdEncoding.EncodeToString(pngData.Bytes())+`"`+
+				` alt="Mermaid diagram" width="200" height="100">`+
+				`</div>`,
+			buff.String())
+	})
+
+	t.Run("assets", func(t *testing.T) {
+		assets := new(AssetFS)
+		md := goldmark.New(
+			goldmark.WithExtensions(&Extender{
+				RenderMode: RenderModeServer,
+				Compiler:   &compiler,
+				Format:     FormatPNG,
+				Assets:     assets,
+			}),
+		)
+
+		var buff bytes.Buffer
+		require.NoError(t, md.Convert([]byte(unlines(
+			"```mermaid",
+			"graph",
+			"```",
+		)), &buff))
+
+		entries, err := assets.ReadDir(".")
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		name := entries[0].Name()
+		assert.Regexp(t, `^[0-9a-f]{16}\.png$`, name)
+		assert.Equal(t,
+			`<div class="mermaid mermaid-rendered" data-processed="true">`+
+				`<img src="/assets/mermaid/`+name+`" alt="Mermaid diagram" width="400" height="200">`+
+				`</div>`,
+			buff.String())
+	})
+}
+
+func TestServerRenderer_UnsupportedFormat(t *testing.T) {
+	t.Parallel()
+
+	r := buildNodeRenderer(&ServerRenderer{
+		Compiler: &compilerStub{
+			CompileF: func(context.Context, *CompileRequest) (*CompileResponse, error) {
+				t.Fatal("Compile must not be called")
+				return nil, nil
+			},
+		},
+		Format: FormatPDF,
+	})
+	reader := text.NewReader([]byte(`A -> B`))
+	give := blockFromReader(reader)
+
+	var buff bytes.Buffer
+	err := r.Render(&buff, reader.Source(), give)
+	assert.ErrorContains(t, err, "format PDF cannot be rendered into HTML")
+}
+
+func TestServerRenderer_UniqueIDs(t *testing.T) {
+	t.Parallel()
+
+	compiler := compilerStub{
+		CompileF: func(context.Context, *CompileRequest) (*CompileResponse, error) {
+			return &CompileResponse{
+				SVG: `<svg id="my-svg"><style>#my-svg .node{}</style>` +
+					`<marker id="my-svg_pointEnd"/><path marker-end="url(#my-svg_pointEnd)"/></svg>`,
+			}, nil
+		},
+	}
+
+	md := goldmark.New(
+		goldmark.WithExtensions(&Extender{
+			RenderMode: RenderModeServer,
+			Compiler:   &compiler,
+			UniqueIDs:  true,
+		}),
+	)
+
+	var buff bytes.Buffer
+	require.NoError(t, md.Convert([]byte(unlines(
+		"```mermaid",
+		"graph",
+		"```",
+		"",
+		"```mermaid",
+		"graph",
+		"```",
+		"",
+		"```mermaid {#checkout}",
+		"graph",
+		"```",
+	)), &buff))
+
+	wantSVG := func(id string) string {
+		return `<svg id="` + id + `"><style>#` + id + ` .node{}</style>` +
+			`<marker id="` + id + `_pointEnd"/><path marker-end="url(#` + id + `_pointEnd)"/></svg>`
+	}
+
+	sum := sha256.Sum256([]byte("graph\n"))
+	base := "mermaid-" + hex.EncodeToString(sum[:4])
+	assert.Equal(t,
+		`<div class="mermaid mermaid-rendered" data-processed="true">`+wantSVG(base+"-svg")+`</div>`+
+			`<div class="mermaid mermaid-rendered" data-processed="true">`+wantSVG(base+"-2-svg")+`</div>`+
+			`<div id="checkout" class="mermaid mermaid-rendered" data-processed="true">`+wantSVG("checkout-svg")+`</div>`,
+		buff.String())
+}
+
+func TestServerRenderer_requestOptions(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu   sync.Mutex
+		reqs = make(map[string]*CompileRequest)
+	)
+	compiler := compilerStub{
+		CompileF: func(_ context.Context, req *CompileRequest) (*CompileResponse, error) {
+			mu.Lock()
+			reqs[strings.TrimSpace(req.Source)] = req
+			mu.Unlock()
+			return &CompileResponse{SVG: `<svg id="` + req.ID + `"><g/></svg>`}, nil
+		},
+	}
+
+	config := map[string]any{"fontSize": 12}
+	md := goldmark.New(
+		goldmark.WithExtensions(&Extender{
+			RenderMode:    RenderModeServer,
+			Compiler:      &compiler,
+			Concurrency:   2,
+			Theme:         "dark",
+			MermaidConfig: config,
+			Background:    "transparent",
+			UniqueIDs:     true,
+		}),
+	)
+
+	var buff bytes.Buffer
+	require.NoError(t, md.Convert([]byte(unlines(
+		"```mermaid {#first}",
+		"graph A",
+		"```",
+		"",
+		"```mermaid {#second}",
+		"graph B",
+		"```",
+	)), &buff))
+
+	require.Len(t, reqs, 2)
+	for src, id := range map[string]string{
+		"graph A": "first-svg",
+		"graph B": "second-svg",
+	} {
+		req := reqs[src]
+		require.NotNil(t, req, "%q", src)
+		assert.Equal(t, id, req.ID, "%q", src)
+		assert.Equal(t, "dark", req.Theme, "%q", src)
+		assert.Equal(t, config, req.Config, "%q", src)
+		assert.Equal(t, "transparent", req.Background, "%q", src)
+	}
+
+	assert.Equal(t,
+		`<div id="first" class="mermaid mermaid-rendered" data-processed="true"><svg id="first-svg"><g/></svg></div>`+
+			`<div id="second" class="mermaid mermaid-rendered" data-processed="true"><svg id="second-svg"><g/></svg></div>`,
+		buff.String())
+}
+
+func TestServerRenderer_UniqueIDs_fenceID(t *testing.T) {
+	t.Parallel()
+
+	compiler := compilerStub{
+		CompileF: func(context.Context, *CompileRequest) (*CompileResponse, error) {
+			return &CompileResponse{
+				SVG: `<svg id="my-svg"><style>#my-svg .node{}</style><g/></svg>`,
+			}, nil
+		},
+	}
+
+	md := goldmark.New(
+		goldmark.WithExtensions(&Extender{
+			RenderMode: RenderModeServer,
+			Compiler:   &compiler,
+			UniqueIDs:  true,
+		}),
+	)
+
+	var buff bytes.Buffer
+	require.NoError(t, md.Convert([]byte(unlines(
+		"```mermaid {#a.b}",
+		"graph",
+		"```",
+		"",
+		"```mermaid {#a:b}",
+		"graph",
+		"```",
+	)), &buff))
+
+	assert.Equal(t,
+		`<div id="a.b" class="mermaid mermaid-rendered" data-processed="true">`+
+			`<svg id="a-b-svg"><style>#a-b-svg .node{}</style><g/></svg></div>`+
+			`<div id="a:b" class="mermaid mermaid-rendered" data-processed="true">`+
+			`<svg id="a-b-svg-2"><style>#a-b-svg-2 .node{}</style><g/></svg></div>`,
+		buff.String())
+}
+
+func TestServerRenderer_SVGTransformers(t *testing.T) {
+	t.Parallel()
+
+	compiler := compilerStub{
+		CompileF: func(context.Context, *CompileRequest) (*CompileResponse, error) {
+			return &CompileResponse{
+				SVG: `<svg id="my-svg" style="max-width: 50px;" viewBox="0 0 50 20"><!-- x --><g/></svg>`,
+			}, nil
+		},
+	}
+
+	md := goldmark.New(
+		goldmark.WithExtensions(&Extender{
+			RenderMode: RenderModeServer,
+			Compiler:   &compiler,
+			UniqueIDs:  true,
+			SVGTransformers: []SVGTransformer{
+				StripSVGComments,
+				SVGTransformerFunc(func(doc *SVGDocument) error {
+					// Runs after UniqueIDs.
+					id, _ := doc.Root().Attribute("id")
+					doc.Root().SetAttribute("data-id", id)
+					return nil
+				}),
+				&RemoveSVGStyles{Properties: []string{"max-width"}},
+			},
+		}),
+	)
+
+	var buff bytes.Buffer
+	require.NoError(t, md.Convert([]byte(unlines(
+		"```mermaid {#flow}",
+		"graph",
+		"```",
+	)), &buff))
+
+	assert.Equal(t,
+		`<div id="flow" class="mermaid mermaid-rendered" data-processed="true">`+
+			`<svg id="flow-svg" viewBox="0 0 50 20" data-id="flow-svg"><g/></svg></div>`,
+		buff.String())
+}
+
+func TestServerRenderer_SVGTransformers_error(t *testing.T) {
+	t.Parallel()
+
+	compiler := compilerStub{
+		CompileF: func(context.Context, *CompileRequest) (*CompileResponse, error) {
+			return &CompileResponse{SVG: `<svg/>`}, nil
+		},
+	}
+
+	md := goldmark.New(
+		goldmark.WithExtensions(&Extender{
+			RenderMode: RenderModeServer,
+			Compiler:   &compiler,
+			SVGTransformers: []SVGTransformer{
+				SVGTransformerFunc(func(*SVGDocument) error {
+					return errors.New("great sadness")
+				}),
+			},
+		}),
+	)
+
+	var buff bytes.Buffer
+	err := md.Convert([]byte(unlines(
+		"```mermaid",
+		"graph",
+		"```",
+	)), &buff)
+	assert.ErrorContains(t, err, "transform svg: great sadness")
+}
+
+func TestServerRenderer_Accessible(t *testing.T) {
+	t.Parallel()
+
+	compiler := compilerStub{
+		CompileF: func(context.Context, *CompileRequest) (*CompileResponse, error) {
+			return &CompileResponse{
+				SVG: `<svg id="my-svg" role="graphics-document document"><g/></svg>`,
+			}, nil
+		},
+	}
+
+	var diagnostics []*Diagnostic
+	md := goldmark.New(
+		goldmark.WithExtensions(&Extender{
+			RenderMode: RenderModeServer,
+			Compiler:   &compiler,
+			Accessible: true,
+			Diagnostics: DiagnosticHandlerFunc(func(d *Diagnostic) {
+				diagnostics = append(diagnostics, d)
+			}),
+		}),
+	)
+
+	var buff bytes.Buffer
+	require.NoError(t, md.Convert([]byte(unlines(
+		"```mermaid {#checkout}",
+		"graph",
+		"  accTitle: Checkout",
+		"  accDescr: How orders are placed",
+		"```",
+		"",
+		"```mermaid {#override alt=\"Payments & refunds\"}",
+		"graph",
+		"  accTitle: Payments",
+		"```",
+		"",
+		"```mermaid {#unnamed}",
+		"graph",
+		"```",
+	)), &buff))
+
+	assert.Equal(t,
+		`<div id="checkout" class="mermaid mermaid-rendered" data-processed="true">`+
+			`<svg id="my-svg" role="img" aria-labelledby="checkout-svg-title" aria-describedby="checkout-svg-desc">`+
+			`<title id="checkout-svg-title">Checkout</title>`+
+			`<desc id="checkout-svg-desc">How orders are placed</desc><g/></svg></div>`+
+			`<div id="override" class="mermaid mermaid-rendered" data-processed="true">`+
+			`<svg id="my-svg" role="img" aria-labelledby="override-svg-title">`+
+			`<title id="override-svg-title">Payments &amp; refunds</title><g/></svg></div>`+
+			`<div id="unnamed" class="mermaid mermaid-rendered" data-processed="true">`+
+			`<svg id="my-svg" role="img"><g/></svg></div>`,
+		buff.String())
+
+	require.Len(t, diagnostics, 1)
+	assert.Equal(t, "unnamed", diagnostics[0].ID)
+	assert.Equal(t, "graph\n", diagnostics[0].Source)
+	assert.Equal(t,
+		"unnamed: diagram has no accessible name: add accTitle or a title attribute",
+		diagnostics[0].String())
+}
+
+func TestServerRenderer_Accessible_image(t *testing.T) {
+	t.Parallel()
+
+	compiler := compilerStub{
+		CompileF: func(context.Context, *CompileRequest) (*CompileResponse, error) {
+			return &CompileResponse{SVG: `<svg viewBox="0 0 20 10"></svg>`}, nil
+		},
+	}
+
+	assets := new(AssetFS)
+	md := goldmark.New(
+		goldmark.WithExtensions(&Extender{
+			RenderMode: RenderModeServer,
+			Compiler:   &compiler,
+			Assets:     assets,
+			Accessible: true,
+		}),
+	)
+
+	var buff bytes.Buffer
+	require.NoError(t, md.Convert([]byte(unlines(
+		"```mermaid {title=\"Checkout flow\"}",
+		"graph",
+		"```",
+	)), &buff))
+
+	assert.Contains(t, buff.String(), `alt="Checkout flow"`)
+}
+
+func TestServerRenderer_imageAltText(t *testing.T) {
+	t.Parallel()
+
+	compiler := compilerStub{
+		CompileF: func(context.Context, *CompileRequest) (*CompileResponse, error) {
+			return &CompileResponse{SVG: `<svg viewBox="0 0 20 10"></svg>`}, nil
+		},
+	}
+
+	tests := []struct {
+		desc string
+		give []string
+		want string
+	}{
+		{
+			desc: "attribute",
+			give: []string{"```mermaid {alt=\"Checkout flow\"}", "graph", "accTitle: Orders", "```"},
+			want: `alt="Checkout flow"`,
+		},
+		{
+			desc: "accTitle",
+			give: []string{"```mermaid", "graph", "accTitle: Orders", "accDescr: How orders are placed", "```"},
+			want: `alt="Orders"`,
+		},
+		{
+			desc: "accDescr",
+			give: []string{"```mermaid", "graph", "accDescr: How orders are placed", "```"},
+			want: `alt="How orders are placed"`,
+		},
+		{
+			desc: "unnamed",
+			give: []string{"```mermaid", "graph", "```"},
+			want: `alt="Mermaid diagram"`,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.desc, func(t *testing.T) {
+			t.Parallel()
+
+			md := goldmark.New(
+				goldmark.WithExtensions(&Extender{
+					RenderMode: RenderModeServer,
+					Compiler:   &compiler,
+					Assets:     new(AssetFS),
+				}),
+			)
+
+			var buff bytes.Buffer
+			require.NoError(t, md.Convert([]byte(unlines(tt.give...)), &buff))
+			assert.Contains(t, buff.String(), tt.want)
+		})
+	}
+}
+
+func TestServerRenderer_Deterministic(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu    sync.Mutex
+		seeds = make(map[string]string)
+	)
+	compiler := compilerStub{
+		CompileF: func(_ context.Context, req *CompileRequest) (*CompileResponse, error) {
+			mu.Lock()
+			seeds[strings.TrimSpace(req.Source)] = req.DeterministicIDSeed
+			mu.Unlock()
+
+			return &CompileResponse{
+				SVG: `<svg viewBox="0 0 85.43750762939453 62"><rect x="0.33333333"/></svg>`,
+			}, nil
+		},
+	}
+
+	md := goldmark.New(
+		goldmark.WithExtensions(&Extender{
+			RenderMode:    RenderModeServer,
+			Compiler:      &compiler,
+			Deterministic: true,
+		}),
+	)
+
+	var buff bytes.Buffer
+	require.NoError(t, md.Convert([]byte(unlines(
+		"```mermaid",
+		"graph A",
+		"```",
+		"",
+		"```mermaid",
+		"graph B",
+		"```",
+	)), &buff))
+
+	assert.Equal(t,
+		`<div class="mermaid mermaid-rendered" data-processed="true">`+
+			`<svg viewBox="0 0 85.438 62"><rect x=".333"/></svg></div>`+
+			`<div class="mermaid mermaid-rendered" data-processed="true">`+
+			`<svg viewBox="0 0 85.438 62"><rect x=".333"/></svg></div>`,
+		buff.String())
+
+	assert.Equal(t, map[string]string{
+		"graph A": deterministicSeed([]byte("graph A\n")),
+		"graph B": deterministicSeed([]byte("graph B\n")),
+	}, seeds)
+}
+
+func TestServerRenderer_SharedStyles(t *testing.T) {
+	t.Parallel()
+
+	compiler := compilerStub{
+		CompileF: func(_ context.Context, req *CompileRequest) (*CompileResponse, error) {
+			var css string
+			if strings.Contains(req.Source, "classDef red") {
+				css = `#my-svg .red&gt;*{fill:red!important;}`
+			}
+			if strings.Contains(req.Source, "dark") {
+				css += `#my-svg .node{fill:#000;}`
+			} else {
+				css += `#my-svg .node{fill:#fff;}`
+			}
+			return &CompileResponse{
+				SVG: `<svg id="my-svg" class="flowchart"><style>#my-svg{fill:#333;}` + css + `</style><g/></svg>`,
+			}, nil
+		},
+	}
+
+	md := goldmark.New(
+		goldmark.WithExtensions(&Extender{
+			RenderMode:   RenderModeServer,
+			Compiler:     &compiler,
+			UniqueIDs:    true,
+			SharedStyles: true,
+		}),
+	)
+
+	var buff bytes.Buffer
+	require.NoError(t, md.Convert([]byte(unlines(
+		"# Diagrams",
+		"",
+		"```mermaid {#a}",
+		"graph",
+		"```",
+		"",
+		"```mermaid {#b}",
+		"graph",
+		"  classDef red fill:#f00",
+		"```",
+		"",
+		"```mermaid {#c}",
+		"graph dark",
+		"```",
+	)), &buff))
+
+	light := extractTestTheme(t, `<svg id="x"><style>#x{fill:#333;}#x .node{fill:#fff;}</style></svg>`)
+	dark := extractTestTheme(t, `<svg id="x"><style>#x{fill:#333;}#x .node{fill:#000;}</style></svg>`)
+
+	assert.Equal(t,
+		`<style>`+light.CSS+dark.CSS+`</style>`+
+			`<h1>Diagrams</h1>`+"\n"+
+			`<div id="a" class="mermaid mermaid-rendered" data-processed="true">`+
+			`<svg id="a-svg" class="flowchart `+light.Class+`"><g/></svg></div>`+
+			`<div id="b" class="mermaid mermaid-rendered" data-processed="true">`+
+			`<svg id="b-svg" class="flowchart `+light.Class+`">`+
+			`<style>#b-svg .red&gt;*{fill:red!important;}</style><g/></svg></div>`+
+			`<div id="c" class="mermaid mermaid-rendered" data-processed="true">`+
+			`<svg id="c-svg" class="flowchart `+dark.Class+`"><g/></svg></div>`,
+		buff.String())
+}
+
+func TestServerRenderer_SharedStyles_sanitize(t *testing.T) {
+	t.Parallel()
+
+	compiler := compilerStub{
+		CompileF: func(context.Context, *CompileRequest) (*CompileResponse, error) {
+			return &CompileResponse{
+				SVG: `<svg id="my-svg"><style>` +
+					`#my-svg .a{background:url(https://example.com/x.png);}` +
+					`#my-svg .b{content:"&lt;/style&gt;&lt;script&gt;alert(1)&lt;/script&gt;";}` +
+					`</style></svg>`,
+			}, nil
+		},
+	}
+
+	md := goldmark.New(
+		goldmark.WithExtensions(&Extender{
+			RenderMode:   RenderModeServer,
+			Compiler:     &compiler,
+			SharedStyles: true,
+			Sanitize:     true,
+		}),
+	)
+
+	var buff bytes.Buffer
+	require.NoError(t, md.Convert([]byte(unlines(
+		"```mermaid",
+		"graph",
+		"```",
+	)), &buff))
+
+	got := buff.String()
+	assert.NotContains(t, got, "example.com")
+	assert.NotContains(t, got, "</style><script>")
+	assert.Contains(t, got, `content:"<\/style><script>alert(1)<\/script>";`)
+}
+
+func TestServerRenderer_SharedStyles_minify(t *testing.T) {
+	t.Parallel()
+
+	compiler := compilerStub{
+		CompileF: func(context.Context, *CompileRequest) (*CompileResponse, error) {
+			return &CompileResponse{
+				SVG: `<svg id="my-svg"><style>` +
+					`#my-svg .node { fill: #fff; }  #my-svg .node { fill: #fff; }` +
+					`</style></svg>`,
+			}, nil
+		},
+	}
+
+	md := goldmark.New(
+		goldmark.WithExtensions(&Extender{
+			RenderMode:      RenderModeServer,
+			Compiler:        &compiler,
+			SharedStyles:    true,
+			SVGTransformers: []SVGTransformer{new(MinifySVG)},
+		}),
+	)
+
+	var buff bytes.Buffer
+	require.NoError(t, md.Convert([]byte(unlines(
+		"```mermaid",
+		"graph",
+		"```",
+	)), &buff))
+
+	theme := extractTestTheme(t,
+		`<svg id="x"><style>#x .node { fill: #fff; }  #x .node { fill: #fff; }</style></svg>`)
+	assert.Equal(t,
+		`<style>.`+theme.Class+` .node{fill: #fff}</style>`+
+			`<div class="mermaid mermaid-rendered" data-processed="true">`+
+			`<svg id="my-svg" class="`+theme.Class+`"></svg></div>`,
+		buff.String())
+}
+
+func TestServerRenderer_SharedStyles_toolbar(t *testing.T) {
+	t.Parallel()
+
+	compiler := compilerStub{
+		CompileF: func(context.Context, *CompileRequest) (*CompileResponse, error) {
+			return &CompileResponse{
+				SVG: `<svg id="my-svg"><style>#my-svg .node{fill:#fff;}</style><g/></svg>`,
+			}, nil
+		},
+	}
+
+	md := goldmark.New(
+		goldmark.WithExtensions(&Extender{
+			RenderMode:   RenderModeServer,
+			Compiler:     &compiler,
+			SharedStyles: true,
+			Toolbar:      true,
+		}),
+	)
+
+	var buff bytes.Buffer
+	require.NoError(t, md.Convert([]byte(unlines(
+		"```mermaid",
+		"graph",
+		"```",
+	)), &buff))
+
+	theme := extractTestTheme(t, `<svg id="x"><style>#x .node{fill:#fff;}</style></svg>`)
+	got := buff.String()
+	assert.True(t, strings.HasPrefix(got, `<style>`+theme.CSS+`</style>`), "got %q", got)
+	assert.Contains(t, got,
+		_toolbarOpen+`<div class="mermaid mermaid-rendered" data-processed="true">`+
+			`<svg id="my-svg" class="`+theme.Class+`"><g/></svg></div>`)
+
+	// Downloads copy the shared theme back into the SVG.
+	assert.Contains(t, got, "sharedStyles(svg)")
+	assert.Contains(t, _toolbarJS, "'"+_themeClassPrefix+"'")
+}
+
+// Without a StyleBlock, SVGs keep their own stylesheets.
+func TestServerRenderer_SharedStyles_noStyleBlock(t *testing.T) {
+	t.Parallel()
+
+	const svg = `<svg id="my-svg"><style>#my-svg .node{fill:#fff;}</style></svg>`
+	compiler := compilerStub{
+		CompileF: func(context.Context, *CompileRequest) (*CompileResponse, error) {
+			return &CompileResponse{SVG: svg}, nil
+		},
+	}
+
+	md := goldmark.New(
+		goldmark.WithParserOptions(
+			parser.WithASTTransformers(
+				util.Prioritized(&Transformer{}, 100),
+			),
+		),
+		goldmark.WithRendererOptions(
+			renderer.WithNodeRenderers(
+				util.Prioritized(&ServerRenderer{
+					Compiler:     &compiler,
+					SharedStyles: true,
+				}, 100),
+			),
+		),
+	)
+
+	var buff bytes.Buffer
+	require.NoError(t, md.Convert([]byte(unlines(
+		"```mermaid",
+		"graph",
+		"```",
+	)), &buff))
+	assert.Contains(t, buff.String(), svg)
+	assert.NotContains(t, buff.String(), "mermaid-theme-")
+}
+
+func extractTestTheme(t *testing.T, svg string) *themeStyle {
+	t.Helper()
+
+	doc, err := ParseSVG(svg)
+	require.NoError(t, err)
+	theme := extractThemeStyle(doc, nil)
+	require.NotNil(t, theme)
+	return theme
+}
+
+func TestServerRenderer_Sizing(t *testing.T) {
+	t.Parallel()
+
+	var (
+		mu     sync.Mutex
+		widths = make(map[string]int)
+	)
+	compiler := compilerStub{
+		CompileF: func(_ context.Context, req *CompileRequest) (*CompileResponse, error) {
+			mu.Lock()
+			widths[strings.TrimSpace(req.Source)] = req.Width
+			mu.Unlock()
+
+			return &CompileResponse{
+				SVG: `<svg width="100%" viewBox="0 0 200 100" style="max-width: 200px;"></svg>`,
+			}, nil
+		},
+	}
+
+	var diagnostics []*Diagnostic
+	md := goldmark.New(
+		goldmark.WithExtensions(&Extender{
+			RenderMode: RenderModeServer,
+			Compiler:   &compiler,
+			Sizing:     Sizing{Mode: SizeMaxWidth, Width: 150},
+			Diagnostics: DiagnosticHandlerFunc(func(d *Diagnostic) {
+				diagnostics = append(diagnostics, d)
+			}),
+		}),
+	)
+
+	var buff bytes.Buffer
+	require.NoError(t, md.Convert([]byte(unlines(
+		"```mermaid {#default}",
+		"graph default",
+		"```",
+		"",
+		"```mermaid {#fixed width=400}",
+		"graph fixed",
+		"```",
+		"",
+		"```mermaid {#fill size=fill}",
+		"graph fill",
+		"```",
+		"",
+		"```mermaid {#auto size=auto}",
+		"graph auto",
+		"```",
+		"",
+		"```mermaid {#invalid size=huge}",
+		"graph invalid",
+		"```",
+		"",
+		"```mermaid {#nan width=abc}",
+		"graph nan",
+		"```",
+		"",
+		"```mermaid {#zero max-width=0}",
+		"graph zero",
+		"```",
+	)), &buff))
+
+	assert.Equal(t,
+		`<div id="default" class="mermaid mermaid-rendered" data-processed="true">`+
+			`<svg width="100%" viewBox="0 0 200 100" style="max-width: 150px;"></svg></div>`+
+			`<div id="fixed" class="mermaid mermaid-rendered" data-processed="true">`+
+			`<svg width="400" viewBox="0 0 200 100" height="200"></svg></div>`+
+			`<div id="fill" class="mermaid mermaid-rendered" data-processed="true">`+
+			`<svg width="100%" viewBox="0 0 200 100"></svg></div>`+
+			`<div id="auto" class="mermaid mermaid-rendered" data-processed="true">`+
+			`<svg width="100%" viewBox="0 0 200 100" style="max-width: 200px;"></svg></div>`+
+			`<div id="invalid" class="mermaid mermaid-rendered" data-processed="true">`+
+			`<svg width="100%" viewBox="0 0 200 100" style="max-width: 150px;"></svg></div>`+
+			`<div id="nan" class="mermaid mermaid-rendered" data-processed="true">`+
+			`<svg width="100%" viewBox="0 0 200 100" style="max-width: 150px;"></svg></div>`+
+			`<div id="zero" class="mermaid mermaid-rendered" data-processed="true">`+
+			`<svg width="100%" viewBox="0 0 200 100" style="max-width: 150px;"></svg></div>`,
+		buff.String())
+
+	// Only fixed sizes are passed to the compiler.
+	assert.Equal(t, map[string]int{
+		"graph default": 0,
+		"graph fixed":   400,
+		"graph fill":    0,
+		"graph auto":    0,
+		"graph invalid": 0,
+		"graph nan":     0,
+		"graph zero":    0,
+	}, widths)
+
+	require.Len(t, diagnostics, 3)
+	assert.Equal(t,
+		`invalid: invalid size "huge": must be auto, intrinsic, or fill`,
+		diagnostics[0].String())
+	assert.Equal(t,
+		`nan: invalid width "abc": not a number`,
+		diagnostics[1].String())
+	assert.Equal(t,
+		`zero: invalid max-width "0": must be positive`,
+		diagnostics[2].String())
+}
+
+func TestServerRenderer_Sizing_invalidCompileError(t *testing.T) {
+	t.Parallel()
+
+	compiler := compilerStub{
+		CompileF: func(context.Context, *CompileRequest) (*CompileResponse, error) {
+			return nil, errors.New("syntax error")
+		},
+	}
+
+	var diagnostics []*Diagnostic
+	md := goldmark.New(
+		goldmark.WithExtensions(&Extender{
+			RenderMode:   RenderModeServer,
+			Compiler:     &compiler,
+			ErrorHandler: RenderErrorBox,
+			Diagnostics: DiagnosticHandlerFunc(func(d *Diagnostic) {
+				diagnostics = append(diagnostics, d)
+			}),
+		}),
+	)
+
+	var buff bytes.Buffer
+	require.NoError(t, md.Convert([]byte(unlines(
+		"```mermaid {#bad width=abc}",
+		"graph",
+		"```",
+	)), &buff))
+
+	assert.Contains(t, buff.String(), "syntax error")
+	require.Len(t, diagnostics, 1)
+	assert.Equal(t, `bad: invalid width "abc": not a number`, diagnostics[0].String())
+}
+
+func TestServerRenderer_Sizing_image(t *testing.T) {
+	t.Parallel()
+
+	compiler := compilerStub{
+		CompileF: func(context.Context, *CompileRequest) (*CompileResponse, error) {
+			return &CompileResponse{SVG: `<svg viewBox="0 0 200 100"></svg>`}, nil
+		},
+	}
+
+	md := goldmark.New(
+		goldmark.WithExtensions(&Extender{
+			RenderMode: RenderModeServer,
+			Compiler:   &compiler,
+			Assets:     new(AssetFS),
+		}),
+	)
+
+	tests := []struct {
+		name  string
+		attrs string
+		want  string
+	}{
+		{name: "auto", want: `width="200" height="100">`},
+		{name: "fixed", attrs: "width=50", want: `width="50" height="25">`},
+		{name: "fill", attrs: "size=fill", want: `style="width: 100%; height: auto;">`},
+		{
+			name:  "max width",
+			attrs: `max-width="600px"`,
+			want:  `style="width: 100%; max-width: 600px; height: auto;">`,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var buff bytes.Buffer
+			require.NoError(t, md.Convert([]byte(unlines(
+				"```mermaid {"+tt.attrs+"}",
+				"graph",
+				"```",
+			)), &buff))
+
+			assert.Contains(t, buff.String(), tt.want)
+		})
+	}
+}
+
+// TestServerRenderer_Sizing_unknownSize verifies that a fixed width
+// is kept for images whose size can't be determined.
+func TestServerRenderer_Sizing_unknownSize(t *testing.T) {
+	t.Parallel()
+
+	compiler := compilerStub{
+		CompileF: func(context.Context, *CompileRequest) (*CompileResponse, error) {
+			return &CompileResponse{SVG: `<svg><g/></svg>`}, nil
+		},
+	}
+
+	md := goldmark.New(
+		goldmark.WithExtensions(&Extender{
+			RenderMode: RenderModeServer,
+			Compiler:   &compiler,
+			Assets:     new(AssetFS),
+		}),
+	)
+
+	tests := []struct {
+		name    string
+		attrs   string
+		want    string
+		notWant string
+	}{
+		{name: "auto", notWant: "width="},
+		{name: "fixed", attrs: "width=300", want: `width="300">`, notWant: "height="},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var buff bytes.Buffer
+			require.NoError(t, md.Convert([]byte(unlines(
+				"```mermaid {"+tt.attrs+"}",
+				"graph",
+				"```",
+			)), &buff))
+
+			if len(tt.want) > 0 {
+				assert.Contains(t, buff.String(), tt.want)
+			}
+			assert.NotContains(t, buff.String(), tt.notWant)
+		})
+	}
+}
+
+func TestServerRenderer_Sanitize(t *testing.T) {
+	t.Parallel()
+
+	compiler := compilerStub{
+		CompileF: func(context.Context, *CompileRequest) (*CompileResponse, error) {
+			return &CompileResponse{
+				SVG: `<svg id="my-svg"><a xlink:href="javascript:alert(1)"><g class="node"/></a>` +
+					`<foreignObject><div>A<img src="x" onerror="alert(1)"></div></foreignObject></svg>`,
+			}, nil
+		},
+	}
+
+	md := goldmark.New(
+		goldmark.WithExtensions(&Extender{
+			RenderMode: RenderModeServer,
+			Compiler:   &compiler,
+			Sanitize:   true,
+			SVGTransformers: []SVGTransformer{
+				// Sanitize runs after this.
+				SVGTransformerFunc(func(doc *SVGDocument) error {
+					doc.Root().SetAttribute("onload", "alert(1)")
+					return nil
+				}),
+			},
+		}),
+	)
+
+	var buff bytes.Buffer
+	require.NoError(t, md.Convert([]byte(unlines(
+		"```mermaid",
+		"graph",
+		"```",
+	)), &buff))
+
+	assert.Equal(t,
+		`<div class="mermaid mermaid-rendered" data-processed="true">`+
+			`<svg id="my-svg"><a><g class="node"/></a>`+
+			`<foreignObject><div>A</div></foreignObject></svg></div>`,
+		buff.String())
+}
+
+func TestServerRenderer_responseSize(t *testing.T) {
+	t.Parallel()
+
+	// Not a real PNG so its size can't be decoded.
+	data := []byte("\x89PNG")
+	compiler := compilerStub{
+		CompileF: func(context.Context, *CompileRequest) (*CompileResponse, error) {
+			return &CompileResponse{
+				Data:   data,
+				Width:  320.4,
+				Height: 180.6,
+			}, nil
+		},
+	}
+
+	md := goldmark.New(
+		goldmark.WithExtensions(&Extender{
+			RenderMode: RenderModeServer,
+			Compiler:   &compiler,
+			Format:     FormatPNG,
+		}),
+	)
+
+	var buff bytes.Buffer
+	require.NoError(t, md.Convert([]byte(unlines(
+		"```mermaid",
+		"graph",
+		"```",
+	)), &buff))
+
+	assert.Equal(t,
+		`<div class="mermaid mermaid-rendered" data-processed="true">`+
+			`<img src="data:image/png;base64,`+base64.StdEncoding.EncodeToString(data)+`"`+
+			` alt="Mermaid diagram" width="320" height="181">`+
+			`</div>`,
+		buff.String())
+}
+
+func TestServerRenderer_Warnings(t *testing.T) {
+	t.Parallel()
+
+	compiler := compilerStub{
+		CompileF: func(context.Context, *CompileRequest) (*CompileResponse, error) {
+			return &CompileResponse{
+				SVG:      `<svg><g/></svg>`,
+				Warnings: []string{"unknown directive"},
+			}, nil
+		},
+	}
+
+	var diagnostics []*Diagnostic
+	md := goldmark.New(
+		goldmark.WithExtensions(&Extender{
+			RenderMode: RenderModeServer,
+			Compiler:   &compiler,
+			Diagnostics: DiagnosticHandlerFunc(func(d *Diagnostic) {
+				diagnostics = append(diagnostics, d)
+			}),
+		}),
+	)
+
+	var buff bytes.Buffer
+	require.NoError(t, md.Convert([]byte(unlines(
+		"```mermaid {#flow}",
+		"graph",
+		"```",
+	)), &buff))
+
+	require.Len(t, diagnostics, 1)
+	assert.Equal(t, "flow: mermaid: unknown directive", diagnostics[0].String())
+}
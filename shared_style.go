@@ -0,0 +1,197 @@
+package mermaid
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"regexp"
+	"strings"
+
+	"github.com/yuin/goldmark/util"
+)
+
+// _themeClassPrefix is the prefix of classes
+// that scope shared theme stylesheets.
+const _themeClassPrefix = "mermaid-theme-"
+
+// themeStyle is the theme stylesheet of a diagram
+// extracted from its SVG to be shared with other diagrams.
+type themeStyle struct {
+	// Class is added to the root <svg> element
+	// to apply the stylesheet to it.
+	// Diagrams with identical themes share a class.
+	Class string
+
+	// CSS holds the theme's rules scoped to Class.
+	CSS string
+}
+
+// _classDefRe matches classDef statements in diagram sources.
+// Mermaid generates CSS rules for these.
+var _classDefRe = regexp.MustCompile(`(?m)^\s*classDef\s+(\S+)`)
+
+// classDefNames returns the names of classes defined
+// with classDef in a diagram.
+func classDefNames(source []byte) []string {
+	var names []string
+	for _, m := range _classDefRe.FindAllSubmatch(source, -1) {
+		for _, name := range strings.Split(string(m[1]), ",") {
+			if name = strings.TrimSpace(name); len(name) > 0 {
+				names = append(names, name)
+			}
+		}
+	}
+	return names
+}
+
+// extractThemeStyle removes the theme rules from the stylesheet
+// of an SVG generated by Mermaid, scopes them to a class,
+// and adds that class to the root <svg> element.
+//
+// Rules that are specific to the diagram stay in the SVG:
+// rules for classes defined with classDef,
+// and rules that refer to elements inside the SVG by ID.
+//
+// It returns nil if the SVG doesn't have any theme rules.
+func extractThemeStyle(doc *SVGDocument, classDefs []string) *themeStyle {
+	root := doc.Root()
+	id, ok := root.Attribute("id")
+	if !ok || len(id) == 0 {
+		return nil
+	}
+
+	var theme strings.Builder
+	children := root.Children[:0]
+	for _, c := range root.Children {
+		e, ok := c.(*SVGElement)
+		if !ok || e.Name.Local != "style" {
+			children = append(children, c)
+			continue
+		}
+
+		var kept strings.Builder
+		for _, rule := range splitCSSRules(e.Text()) {
+			if isThemeRule(rule, id, classDefs) {
+				theme.WriteString(rule)
+			} else {
+				kept.WriteString(rule)
+			}
+		}
+
+		if strings.TrimSpace(kept.String()) == "" {
+			continue
+		}
+		e.Children = []SVGNode{&SVGText{Data: kept.String()}}
+		children = append(children, e)
+	}
+	if theme.Len() == 0 {
+		return nil
+	}
+	root.Children = children
+
+	// Identify the theme by its rules with the ID taken out
+	// so that diagrams with different IDs share it.
+	const placeholder = "\x00"
+	css := strings.ReplaceAll(theme.String(), "#"+id, placeholder)
+	sum := sha256.Sum256([]byte(css))
+	class := _themeClassPrefix + hex.EncodeToString(sum[:4])
+
+	classes, _ := root.Attribute("class")
+	root.SetAttribute("class", strings.TrimSpace(classes+" "+class))
+
+	return &themeStyle{
+		Class: class,
+		CSS:   strings.ReplaceAll(css, placeholder, "."+class),
+	}
+}
+
+// isThemeRule reports whether a CSS rule from the stylesheet
+// of the SVG with the given ID can be shared with other diagrams.
+func isThemeRule(rule, id string, classDefs []string) bool {
+	if len(strings.TrimSpace(rule)) == 0 {
+		return false
+	}
+
+	// "#my-svg" scopes the rule to the diagram,
+	// but "#my-svg-arrow" refers to an element inside it.
+	if _, longer := findCSSName(rule, "#"+id); longer {
+		return false
+	}
+
+	for _, name := range classDefs {
+		if exact, _ := findCSSName(rule, "."+name); exact {
+			return false
+		}
+	}
+	return true
+}
+
+// findCSSName reports whether css contains name on its own,
+// and whether it contains longer names that start with name.
+func findCSSName(css, name string) (exact, longer bool) {
+	for i := 0; ; {
+		idx := strings.Index(css[i:], name)
+		if idx < 0 {
+			return exact, longer
+		}
+		end := i + idx + len(name)
+		if end < len(css) && isCSSNameChar(css[end]) {
+			longer = true
+		} else {
+			exact = true
+		}
+		i = end
+	}
+}
+
+func isCSSNameChar(c byte) bool {
+	return c == '-' || c == '_' ||
+		'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' ||
+		c >= 0x80
+}
+
+// transformStyleSheet runs the shared theme stylesheet through transformers
+// as the only <style> element of an otherwise empty SVG.
+// This way, transformers like MinifySVG and SanitizeSVG
+// treat it the same as the stylesheets left inside each SVG.
+func transformStyleSheet(css string, transformers []SVGTransformer) (string, error) {
+	if len(transformers) == 0 {
+		return css, nil
+	}
+
+	doc, err := ParseSVG(`<svg xmlns="http://www.w3.org/2000/svg"><style></style></svg>`)
+	if err != nil {
+		return "", err
+	}
+	doc.Walk(func(e *SVGElement) {
+		if e.Name.Local == "style" {
+			e.Children = []SVGNode{&SVGText{Data: css}}
+		}
+	})
+
+	for _, t := range transformers {
+		if err := t.TransformSVG(doc); err != nil {
+			return "", err
+		}
+	}
+
+	var out strings.Builder
+	doc.Walk(func(e *SVGElement) {
+		if e.Name.Local == "style" {
+			out.WriteString(e.Text())
+		}
+	})
+	return out.String(), nil
+}
+
+// writeStyleSheet writes the shared theme stylesheet
+// as a <style> element.
+func writeStyleSheet(w util.BufWriter, css string) {
+	if len(css) == 0 {
+		return
+	}
+
+	_, _ = w.WriteString("<style>")
+	// Don't let the stylesheet end the <style> element early.
+	_, _ = w.WriteString(strings.ReplaceAll(css, "</", `<\/`))
+	_, _ = w.WriteString("</style>")
+}
@@ -0,0 +1,144 @@
+package mermaid
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExtractThemeStyle(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		give      string
+		classDefs []string
+		wantSVG   string
+		wantCSS   string // with "THEME" in place of the class
+	}{
+		{
+			name: "theme only",
+			give: `<svg id="my-svg" class="flowchart"><style>` +
+				`#my-svg{fill:#333;}@keyframes dash{to{stroke-dashoffset:0;}}#my-svg .node rect{fill:#ECECFF;}` +
+				`</style><g/></svg>`,
+			wantSVG: `<svg id="my-svg" class="flowchart THEME"><g/></svg>`,
+			wantCSS: `.THEME{fill:#333;}@keyframes dash{to{stroke-dashoffset:0;}}.THEME .node rect{fill:#ECECFF;}`,
+		},
+		{
+			name: "diagram rules",
+			give: `<svg id="a"><style>` +
+				`#a .node{fill:#fff;}` +
+				`#a .red>*{fill:red!important;}#a .red span{fill:red!important;}` +
+				`#a .reddish{fill:pink;}` +
+				`#a .edge{marker-end:url(#a-arrow);}` +
+				`</style></svg>`,
+			classDefs: []string{"red"},
+			wantSVG: `<svg id="a" class="THEME"><style>` +
+				`#a .red&gt;*{fill:red!important;}#a .red span{fill:red!important;}` +
+				`#a .edge{marker-end:url(#a-arrow);}` +
+				`</style></svg>`,
+			wantCSS: `.THEME .node{fill:#fff;}.THEME .reddish{fill:pink;}`,
+		},
+		{
+			name:      "no theme rules",
+			give:      `<svg id="a"><style>#a .red{fill:red;}</style></svg>`,
+			wantSVG:   `<svg id="a"><style>#a .red{fill:red;}</style></svg>`,
+			classDefs: []string{"red"},
+		},
+		{
+			name:    "no id",
+			give:    `<svg><style>.node{fill:#fff;}</style></svg>`,
+			wantSVG: `<svg><style>.node{fill:#fff;}</style></svg>`,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			doc, err := ParseSVG(tt.give)
+			require.NoError(t, err)
+
+			theme := extractThemeStyle(doc, tt.classDefs)
+			if len(tt.wantCSS) == 0 {
+				assert.Nil(t, theme)
+				assert.Equal(t, tt.wantSVG, doc.String())
+				return
+			}
+
+			require.NotNil(t, theme)
+			assert.True(t, strings.HasPrefix(theme.Class, "mermaid-theme-"), "class: %q", theme.Class)
+			assert.Equal(t, strings.ReplaceAll(tt.wantSVG, "THEME", theme.Class), doc.String())
+			assert.Equal(t, strings.ReplaceAll(tt.wantCSS, "THEME", theme.Class), theme.CSS)
+		})
+	}
+}
+
+func TestExtractThemeStyle_sharedAcrossIDs(t *testing.T) {
+	t.Parallel()
+
+	extract := func(id string) *themeStyle {
+		doc, err := ParseSVG(`<svg id="` + id + `"><style>#` + id + ` .node{fill:#fff;}</style></svg>`)
+		require.NoError(t, err)
+		return extractThemeStyle(doc, nil)
+	}
+
+	a, b := extract("a"), extract("b")
+	assert.Equal(t, a, b)
+
+	doc, err := ParseSVG(`<svg id="a"><style>#a .node{fill:#000;}</style></svg>`)
+	require.NoError(t, err)
+	assert.NotEqual(t, a.Class, extractThemeStyle(doc, nil).Class)
+}
+
+// TestExtractThemeStyle_golden verifies that the stylesheets of SVGs
+// generated by Mermaid are split without losing any rules.
+func TestExtractThemeStyle_golden(t *testing.T) {
+	t.Parallel()
+
+	for i, svg := range goldenSVGs(t) {
+		i, svg := i, svg
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Parallel()
+
+			doc, err := ParseSVG(svg)
+			require.NoError(t, err)
+			id, _ := doc.Root().Attribute("id")
+
+			var before []string
+			doc.Walk(func(e *SVGElement) {
+				if e.Name.Local == "style" {
+					before = append(before, splitCSSRules(e.Text())...)
+				}
+			})
+
+			theme := extractThemeStyle(doc, nil)
+			require.NotNil(t, theme)
+
+			after := splitCSSRules(strings.ReplaceAll(theme.CSS, "."+theme.Class, "#"+id))
+			doc.Walk(func(e *SVGElement) {
+				if e.Name.Local == "style" {
+					after = append(after, splitCSSRules(e.Text())...)
+				}
+			})
+			assert.ElementsMatch(t, before, after)
+		})
+	}
+}
+
+func TestClassDefNames(t *testing.T) {
+	t.Parallel()
+
+	got := classDefNames([]byte(unlines(
+		"graph TD",
+		"  A:::red --> B",
+		"  classDef red fill:#f00",
+		"  classDef green,blue stroke:#0f0",
+		"  classDefault --> C",
+	)))
+	assert.Equal(t, []string{"red", "green", "blue"}, got)
+}
@@ -0,0 +1,225 @@
+package mermaid
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// SizeMode specifies how rendered diagrams are sized.
+type SizeMode int
+
+//go:generate stringer -type SizeMode -trimprefix Size
+
+const (
+	// SizeAuto keeps Mermaid's default sizing:
+	// diagrams are shown at their natural size,
+	// shrinking to fit narrower containers.
+	SizeAuto SizeMode = iota
+
+	// SizeIntrinsic shows diagrams at their natural size,
+	// even if they overflow their container.
+	SizeIntrinsic
+
+	// SizeFill scales diagrams to fill the width of their container.
+	SizeFill
+
+	// SizeFixed shows diagrams at a fixed width
+	// given by Sizing.Width.
+	// The height is scaled to match.
+	SizeFixed
+
+	// SizeMaxWidth scales diagrams to fill the width of their container
+	// up to a maximum width given by Sizing.Width.
+	SizeMaxWidth
+)
+
+// Sizing specifies the dimensions of rendered diagrams.
+//
+//	mermaid.Sizing{Mode: mermaid.SizeMaxWidth, Width: 600}
+//
+// The zero value keeps Mermaid's default sizing.
+type Sizing struct {
+	// Mode specifies how diagrams are sized.
+	Mode SizeMode
+
+	// Width is the width in pixels
+	// for SizeFixed and SizeMaxWidth.
+	// It's ignored for other modes.
+	Width int
+}
+
+func (s Sizing) String() string {
+	switch s.Mode {
+	case SizeFixed, SizeMaxWidth:
+		return fmt.Sprintf("%v(%d)", s.Mode, s.Width)
+	default:
+		return s.Mode.String()
+	}
+}
+
+// normalize returns the Sizing with invalid settings reset.
+// SizeFixed and SizeMaxWidth without a width become SizeAuto.
+func (s Sizing) normalize() Sizing {
+	switch s.Mode {
+	case SizeFixed, SizeMaxWidth:
+		if s.Width <= 0 {
+			return Sizing{}
+		}
+		return s
+	case SizeIntrinsic, SizeFill:
+		return Sizing{Mode: s.Mode}
+	default:
+		return Sizing{}
+	}
+}
+
+// Fence attributes that change the size of a diagram.
+//
+//	```mermaid {width=600}
+//	```mermaid {max-width=600}
+//	```mermaid {size=fill}
+var (
+	_attrSize     = []byte("size")
+	_attrWidth    = []byte("width")
+	_attrMaxWidth = []byte("max-width")
+)
+
+// blockSizing returns the Sizing for a Block
+// based on its fence attributes,
+// or def if it doesn't have any.
+//
+// An error is returned with def if the attributes are invalid.
+func blockSizing(n *Block, def Sizing) (Sizing, error) {
+	if v, ok := attributeString(n, _attrWidth); ok {
+		w, err := parsePixels(v)
+		if err != nil {
+			return def, fmt.Errorf("invalid width %q: %w", v, err)
+		}
+		return Sizing{Mode: SizeFixed, Width: w}, nil
+	}
+
+	if v, ok := attributeString(n, _attrMaxWidth); ok {
+		w, err := parsePixels(v)
+		if err != nil {
+			return def, fmt.Errorf("invalid max-width %q: %w", v, err)
+		}
+		return Sizing{Mode: SizeMaxWidth, Width: w}, nil
+	}
+
+	if v, ok := attributeString(n, _attrSize); ok {
+		switch strings.ToLower(strings.TrimSpace(v)) {
+		case "auto":
+			return Sizing{Mode: SizeAuto}, nil
+		case "intrinsic":
+			return Sizing{Mode: SizeIntrinsic}, nil
+		case "fill":
+			return Sizing{Mode: SizeFill}, nil
+		default:
+			return def, fmt.Errorf("invalid size %q: must be auto, intrinsic, or fill", v)
+		}
+	}
+
+	return def, nil
+}
+
+// parsePixels parses a positive number of pixels
+// with an optional "px" suffix.
+func parsePixels(s string) (int, error) {
+	s = strings.TrimSuffix(strings.TrimSpace(s), "px")
+	f, err := strconv.ParseFloat(s, 64)
+	if err != nil {
+		return 0, fmt.Errorf("not a number")
+	}
+	if f < 1 {
+		return 0, fmt.Errorf("must be positive")
+	}
+	return int(f + 0.5), nil
+}
+
+// resizeSVG sets the width, height, and viewBox of the root <svg> element
+// of doc according to the given Sizing.
+//
+// SVGs without a viewBox get one based on their width and height
+// so that they scale correctly.
+// SVGs without either are left as-is.
+func resizeSVG(doc *SVGDocument, s Sizing) {
+	root := doc.Root()
+	width, height, ok := svgViewBoxSize(root)
+	if !ok {
+		return
+	}
+
+	switch s.normalize().Mode {
+	case SizeIntrinsic:
+		root.SetAttribute("width", formatSVGNumber(width))
+		root.SetAttribute("height", formatSVGNumber(height))
+		removeRootStyles(root, "max-width")
+
+	case SizeFill:
+		root.SetAttribute("width", "100%")
+		root.RemoveAttribute("height")
+		removeRootStyles(root, "max-width")
+
+	case SizeFixed:
+		root.SetAttribute("width", strconv.Itoa(s.Width))
+		root.SetAttribute("height", formatSVGNumber(roundSVGNumber(height*float64(s.Width)/width)))
+		removeRootStyles(root, "max-width")
+
+	case SizeMaxWidth:
+		root.SetAttribute("width", "100%")
+		root.RemoveAttribute("height")
+		removeRootStyles(root, "max-width")
+		style, _ := root.Attribute("style")
+		root.SetAttribute("style", fmt.Sprintf("max-width: %dpx;", s.Width)+prefixSpace(style))
+	}
+}
+
+// svgViewBoxSize returns the size of the viewBox of an <svg> element.
+// If it doesn't have one, one is added based on its width and height.
+func svgViewBoxSize(root *SVGElement) (width, height float64, ok bool) {
+	if viewBox, ok := root.Attribute("viewBox"); ok {
+		return parseViewBox(viewBox)
+	}
+
+	w, werr := parseSVGLength(root, "width")
+	h, herr := parseSVGLength(root, "height")
+	if werr != nil || herr != nil || w <= 0 || h <= 0 {
+		return 0, 0, false
+	}
+	root.SetAttribute("viewBox", "0 0 "+formatSVGNumber(w)+" "+formatSVGNumber(h))
+	return w, h, true
+}
+
+// parseSVGLength parses an absolute length attribute like "100" or "100px".
+func parseSVGLength(e *SVGElement, name string) (float64, error) {
+	v, ok := e.Attribute(name)
+	if !ok {
+		return 0, fmt.Errorf("no %v", name)
+	}
+	return strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(v), "px"), 64)
+}
+
+func removeRootStyles(root *SVGElement, props ...string) {
+	style, ok := root.Attribute("style")
+	if !ok {
+		return
+	}
+	style = removeStyleProperties(style, props)
+	if len(style) == 0 {
+		root.RemoveAttribute("style")
+	} else {
+		root.SetAttribute("style", style)
+	}
+}
+
+func prefixSpace(s string) string {
+	if len(s) == 0 {
+		return s
+	}
+	return " " + s
+}
+
+func roundSVGNumber(f float64) float64 {
+	return float64(int64(f*1000+0.5)) / 1000
+}
@@ -0,0 +1,147 @@
+package mermaid
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResizeSVG(t *testing.T) {
+	t.Parallel()
+
+	// Shaped like Mermaid's output.
+	const mermaidSVG = `<svg width="100%" viewBox="0 0 200 100" style="max-width: 200px; background-color: white;"><g/></svg>`
+
+	tests := []struct {
+		name string
+		give string
+		size Sizing
+		want string
+	}{
+		{
+			name: "auto",
+			give: mermaidSVG,
+			want: mermaidSVG,
+		},
+		{
+			name: "intrinsic",
+			give: mermaidSVG,
+			size: Sizing{Mode: SizeIntrinsic},
+			want: `<svg width="200" viewBox="0 0 200 100" style="background-color: white;" height="100"><g/></svg>`,
+		},
+		{
+			name: "fill",
+			give: mermaidSVG,
+			size: Sizing{Mode: SizeFill},
+			want: `<svg width="100%" viewBox="0 0 200 100" style="background-color: white;"><g/></svg>`,
+		},
+		{
+			name: "fixed",
+			give: mermaidSVG,
+			size: Sizing{Mode: SizeFixed, Width: 300},
+			want: `<svg width="300" viewBox="0 0 200 100" style="background-color: white;" height="150"><g/></svg>`,
+		},
+		{
+			name: "fixed/rounding",
+			give: `<svg viewBox="0 0 300 100"/>`,
+			size: Sizing{Mode: SizeFixed, Width: 100},
+			want: `<svg viewBox="0 0 300 100" width="100" height="33.333"/>`,
+		},
+		{
+			name: "fixed/no width",
+			give: mermaidSVG,
+			size: Sizing{Mode: SizeFixed},
+			want: mermaidSVG,
+		},
+		{
+			name: "max width",
+			give: mermaidSVG,
+			size: Sizing{Mode: SizeMaxWidth, Width: 150},
+			want: `<svg width="100%" viewBox="0 0 200 100" style="max-width: 150px; background-color: white;"><g/></svg>`,
+		},
+		{
+			name: "max width/no style",
+			give: `<svg width="200" height="100" viewBox="0 0 200 100"/>`,
+			size: Sizing{Mode: SizeMaxWidth, Width: 150},
+			want: `<svg width="100%" viewBox="0 0 200 100" style="max-width: 150px;"/>`,
+		},
+		{
+			name: "no viewBox",
+			give: `<svg width="200px" height="100"/>`,
+			size: Sizing{Mode: SizeFill},
+			want: `<svg width="100%" viewBox="0 0 200 100"/>`,
+		},
+		{
+			name: "unknown size",
+			give: `<svg width="100%"/>`,
+			size: Sizing{Mode: SizeFixed, Width: 300},
+			want: `<svg width="100%"/>`,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := transformSVG(tt.give, []SVGTransformer{
+				SVGTransformerFunc(func(doc *SVGDocument) error {
+					resizeSVG(doc, tt.size)
+					return nil
+				}),
+			})
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestSizing_String(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		give Sizing
+		want string
+	}{
+		{Sizing{}, "Auto"},
+		{Sizing{Mode: SizeIntrinsic}, "Intrinsic"},
+		{Sizing{Mode: SizeFill}, "Fill"},
+		{Sizing{Mode: SizeFixed, Width: 300}, "Fixed(300)"},
+		{Sizing{Mode: SizeMaxWidth, Width: 600}, "MaxWidth(600)"},
+		{Sizing{Mode: SizeMode(42)}, "SizeMode(42)"},
+	}
+
+	for _, tt := range tests {
+		assert.Equal(t, tt.want, tt.give.String())
+	}
+}
+
+func TestParsePixels(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		give    string
+		want    int
+		wantErr string
+	}{
+		{give: "300", want: 300},
+		{give: " 300px ", want: 300},
+		{give: "99.6", want: 100},
+		{give: "", wantErr: "not a number"},
+		{give: "50%", wantErr: "not a number"},
+		{give: "0", wantErr: "must be positive"},
+		{give: "-10", wantErr: "must be positive"},
+	}
+
+	for _, tt := range tests {
+		got, err := parsePixels(tt.give)
+		if len(tt.wantErr) > 0 {
+			assert.ErrorContains(t, err, tt.wantErr, "%q", tt.give)
+			continue
+		}
+		if assert.NoError(t, err, "%q", tt.give) {
+			assert.Equal(t, tt.want, got, "%q", tt.give)
+		}
+	}
+}
@@ -0,0 +1,332 @@
+package mermaid
+
+import (
+	"encoding/xml"
+	"errors"
+	"io"
+	"strings"
+)
+
+// SVGNode is a node in an [SVGDocument].
+// It is one of *SVGElement, *SVGText, *SVGComment, or *SVGRaw.
+type SVGNode interface {
+	writeTo(sb *strings.Builder)
+}
+
+// SVGDocument is a parsed SVG document.
+//
+// It is parsed leniently so that HTML inside <foreignObject>
+// elements, as generated by Mermaid, is supported.
+// Writing it back with String reproduces the original document
+// except for insignificant differences in escaping.
+type SVGDocument struct {
+	// Nodes are the top-level nodes of the document.
+	// This includes the root <svg> element
+	// and any declarations or comments around it.
+	Nodes []SVGNode
+}
+
+// SVGElement is an element in an [SVGDocument].
+//
+// Names of elements and attributes are as written in the document.
+// Namespace prefixes are held in Name.Space.
+// For example, xlink:href is {Space: "xlink", Local: "href"}.
+type SVGElement struct {
+	Name     xml.Name
+	Attr     []xml.Attr
+	Children []SVGNode
+
+	selfClosing bool // written as <foo/>
+	unclosed    bool // written without an end tag, like HTML's <br>
+}
+
+var _ SVGNode = (*SVGElement)(nil)
+
+// SVGText is text inside an [SVGElement], without escaping.
+type SVGText struct{ Data string }
+
+var _ SVGNode = (*SVGText)(nil)
+
+// SVGComment is a comment in an [SVGDocument].
+type SVGComment struct{ Data string }
+
+var _ SVGNode = (*SVGComment)(nil)
+
+// SVGRaw is a processing instruction or a directive
+// in an [SVGDocument], like <?xml ...?> or <!DOCTYPE ...>.
+// Data is written out as-is.
+type SVGRaw struct{ Data string }
+
+var _ SVGNode = (*SVGRaw)(nil)
+
+// ParseSVG parses an SVG document.
+func ParseSVG(svg string) (*SVGDocument, error) {
+	dec := xml.NewDecoder(strings.NewReader(svg))
+	dec.Strict = false
+	dec.Entity = xml.HTMLEntity
+
+	var (
+		doc   SVGDocument
+		stack []*SVGElement
+
+		// Set after a self-closing start element.
+		// The decoder reports an end element for it.
+		skipEnd bool
+	)
+	appendNode := func(n SVGNode) {
+		if len(stack) == 0 {
+			doc.Nodes = append(doc.Nodes, n)
+			return
+		}
+		parent := stack[len(stack)-1]
+		parent.Children = append(parent.Children, n)
+	}
+
+	for {
+		tok, err := dec.RawToken()
+		if err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return nil, err
+		}
+
+		switch tok := tok.(type) {
+		case xml.StartElement:
+			off := int(dec.InputOffset())
+			e := &SVGElement{
+				Name:        tok.Name,
+				Attr:        append([]xml.Attr(nil), tok.Attr...),
+				selfClosing: off >= 2 && svg[off-2:off] == "/>",
+			}
+			appendNode(e)
+			if e.selfClosing {
+				skipEnd = true
+			} else {
+				stack = append(stack, e)
+			}
+
+		case xml.EndElement:
+			if skipEnd {
+				skipEnd = false
+				continue
+			}
+
+			// Find the matching element.
+			// Elements opened since then were never closed,
+			// like HTML's <br>.
+			for i := len(stack) - 1; i >= 0; i-- {
+				if stack[i].Name != tok.Name {
+					continue
+				}
+				for _, e := range stack[i+1:] {
+					e.unclosed = true
+					// Move its children after it
+					// so they're written in the same order.
+					parent := stack[i]
+					if idx := indexOfNode(parent.Children, e); idx >= 0 {
+						children := e.Children
+						e.Children = nil
+						parent.Children = append(parent.Children[:idx+1],
+							append(children, parent.Children[idx+1:]...)...)
+					}
+				}
+				stack = stack[:i]
+				break
+			}
+
+		case xml.CharData:
+			appendNode(&SVGText{Data: string(tok)})
+
+		case xml.Comment:
+			appendNode(&SVGComment{Data: string(tok)})
+
+		case xml.ProcInst:
+			data := "<?" + tok.Target
+			if len(tok.Inst) > 0 {
+				data += " " + string(tok.Inst)
+			}
+			appendNode(&SVGRaw{Data: data + "?>"})
+
+		case xml.Directive:
+			appendNode(&SVGRaw{Data: "<!" + string(tok) + ">"})
+		}
+	}
+
+	if doc.Root() == nil {
+		return nil, errors.New("no <svg> element found")
+	}
+	return &doc, nil
+}
+
+func indexOfNode(nodes []SVGNode, n SVGNode) int {
+	for i, c := range nodes {
+		if c == n {
+			return i
+		}
+	}
+	return -1
+}
+
+// Root returns the root <svg> element of the document,
+// or nil if there isn't one.
+func (d *SVGDocument) Root() *SVGElement {
+	for _, n := range d.Nodes {
+		if e, ok := n.(*SVGElement); ok && e.Name.Local == "svg" {
+			return e
+		}
+	}
+	return nil
+}
+
+// Walk calls fn for each element in the document
+// in depth-first order, parents before children.
+func (d *SVGDocument) Walk(fn func(*SVGElement)) {
+	for _, n := range d.Nodes {
+		if e, ok := n.(*SVGElement); ok {
+			e.Walk(fn)
+		}
+	}
+}
+
+// String writes the document back into SVG text.
+func (d *SVGDocument) String() string {
+	var sb strings.Builder
+	for _, n := range d.Nodes {
+		n.writeTo(&sb)
+	}
+	return sb.String()
+}
+
+// Walk calls fn for this element and all elements inside it
+// in depth-first order, parents before children.
+func (e *SVGElement) Walk(fn func(*SVGElement)) {
+	fn(e)
+	for _, c := range e.Children {
+		if ce, ok := c.(*SVGElement); ok {
+			ce.Walk(fn)
+		}
+	}
+}
+
+// Attribute returns the value of the attribute with the given name.
+// Use "prefix:name" for attributes with a namespace prefix.
+func (e *SVGElement) Attribute(name string) (string, bool) {
+	for _, attr := range e.Attr {
+		if attrName(attr.Name) == name {
+			return attr.Value, true
+		}
+	}
+	return "", false
+}
+
+// SetAttribute sets the value of the attribute with the given name,
+// adding it if it doesn't exist.
+// Use "prefix:name" for attributes with a namespace prefix.
+func (e *SVGElement) SetAttribute(name, value string) {
+	for i, attr := range e.Attr {
+		if attrName(attr.Name) == name {
+			e.Attr[i].Value = value
+			return
+		}
+	}
+
+	var xname xml.Name
+	if prefix, local, ok := strings.Cut(name, ":"); ok {
+		xname = xml.Name{Space: prefix, Local: local}
+	} else {
+		xname = xml.Name{Local: name}
+	}
+	e.Attr = append(e.Attr, xml.Attr{Name: xname, Value: value})
+}
+
+// RemoveAttribute removes the attribute with the given name
+// if it exists.
+func (e *SVGElement) RemoveAttribute(name string) {
+	attrs := e.Attr[:0]
+	for _, attr := range e.Attr {
+		if attrName(attr.Name) != name {
+			attrs = append(attrs, attr)
+		}
+	}
+	e.Attr = attrs
+}
+
+// Text returns the concatenated text inside this element
+// and its descendants.
+func (e *SVGElement) Text() string {
+	var sb strings.Builder
+	for _, c := range e.Children {
+		switch c := c.(type) {
+		case *SVGText:
+			sb.WriteString(c.Data)
+		case *SVGElement:
+			sb.WriteString(c.Text())
+		}
+	}
+	return sb.String()
+}
+
+func attrName(name xml.Name) string {
+	if len(name.Space) > 0 {
+		return name.Space + ":" + name.Local
+	}
+	return name.Local
+}
+
+func (e *SVGElement) writeTo(sb *strings.Builder) {
+	sb.WriteString("<")
+	writeXMLName(sb, e.Name)
+	for _, attr := range e.Attr {
+		sb.WriteString(" ")
+		writeXMLName(sb, attr.Name)
+		sb.WriteString(`="`)
+		sb.WriteString(_xmlAttrEscaper.Replace(attr.Value))
+		sb.WriteString(`"`)
+	}
+
+	// Elements that gained children can't be self-closing.
+	if e.selfClosing && len(e.Children) == 0 {
+		sb.WriteString("/>")
+		return
+	}
+	sb.WriteString(">")
+
+	for _, c := range e.Children {
+		c.writeTo(sb)
+	}
+
+	if e.unclosed && len(e.Children) == 0 {
+		return
+	}
+	sb.WriteString("</")
+	writeXMLName(sb, e.Name)
+	sb.WriteString(">")
+}
+
+func (t *SVGText) writeTo(sb *strings.Builder) {
+	sb.WriteString(_xmlTextEscaper.Replace(t.Data))
+}
+
+func (c *SVGComment) writeTo(sb *strings.Builder) {
+	sb.WriteString("<!--")
+	sb.WriteString(c.Data)
+	sb.WriteString("-->")
+}
+
+func (r *SVGRaw) writeTo(sb *strings.Builder) {
+	sb.WriteString(r.Data)
+}
+
+var (
+	_xmlTextEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;")
+	_xmlAttrEscaper = strings.NewReplacer("&", "&amp;", "<", "&lt;", `"`, "&quot;")
+)
+
+func writeXMLName(sb *strings.Builder, name xml.Name) {
+	if len(name.Space) > 0 {
+		sb.WriteString(name.Space)
+		sb.WriteString(":")
+	}
+	sb.WriteString(name.Local)
+}
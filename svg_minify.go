@@ -0,0 +1,423 @@
+package mermaid
+
+import (
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// _defaultMinifyPrecision is the default number of decimal places
+// kept by MinifySVG.
+const _defaultMinifyPrecision = 3
+
+// MinifySVG is an [SVGTransformer] that reduces the size of SVGs
+// without changing how they look.
+//
+//	&mermaid.Extender{
+//		SVGTransformers: []mermaid.SVGTransformer{
+//			&mermaid.MinifySVG{},
+//		},
+//	}
+//
+// It removes comments and whitespace between elements,
+// collapses whitespace in text,
+// rounds numbers in coordinates and sizes,
+// removes empty groups,
+// and removes duplicate rules from stylesheets.
+type MinifySVG struct {
+	// Precision is the number of decimal places
+	// to round numbers in coordinates and sizes to.
+	// Use 0 to round to whole numbers,
+	// or a negative value to disable rounding.
+	//
+	//	precision := 1
+	//	&mermaid.MinifySVG{Precision: &precision}
+	//
+	// Defaults to 3 if unset.
+	Precision *int
+}
+
+var _ SVGTransformer = (*MinifySVG)(nil)
+
+// Attributes holding coordinates and sizes.
+// Numbers in these are rounded.
+var _svgGeometryAttrs = map[string]struct{}{
+	"cx": {}, "cy": {}, "d": {}, "dx": {}, "dy": {},
+	"height": {}, "points": {}, "r": {}, "refX": {}, "refY": {},
+	"rx": {}, "ry": {}, "transform": {}, "viewBox": {}, "width": {},
+	"x": {}, "x1": {}, "x2": {}, "y": {}, "y1": {}, "y2": {},
+	"markerWidth": {}, "markerHeight": {},
+}
+
+// Elements whose text is rendered.
+// Whitespace inside these is significant
+// up to the point that runs of it are shown as a single space.
+var _svgTextElements = map[string]struct{}{
+	"text":          {},
+	"textPath":      {},
+	"tspan":         {},
+	"title":         {},
+	"desc":          {},
+	"foreignObject": {},
+}
+
+// TransformSVG minifies the SVG.
+func (m *MinifySVG) TransformSVG(doc *SVGDocument) error {
+	precision := _defaultMinifyPrecision
+	if m.Precision != nil {
+		precision = *m.Precision
+	}
+
+	doc.Nodes = minifySVGNodes(doc.Nodes, precision, false)
+	return nil
+}
+
+func minifySVGNodes(nodes []SVGNode, precision int, inText bool) []SVGNode {
+	kept := nodes[:0]
+	for _, n := range nodes {
+		switch n := n.(type) {
+		case *SVGComment:
+			continue
+
+		case *SVGText:
+			if !inText {
+				if len(strings.TrimSpace(n.Data)) == 0 {
+					continue
+				}
+			}
+			n.Data = collapseSpace(n.Data)
+
+		case *SVGElement:
+			minifySVGElement(n, precision, inText)
+			if isEmptyGroup(n) {
+				continue
+			}
+		}
+		kept = append(kept, n)
+	}
+	return kept
+}
+
+func minifySVGElement(e *SVGElement, precision int, inText bool) {
+	attrs := e.Attr[:0]
+	for _, attr := range e.Attr {
+		name := attrName(attr.Name)
+		switch {
+		case name == "style":
+			attr.Value = minifyDeclarations(attr.Value)
+			if len(attr.Value) == 0 {
+				continue
+			}
+		case name == "class":
+			attr.Value = strings.Join(strings.Fields(attr.Value), " ")
+		default:
+			if _, ok := _svgGeometryAttrs[name]; ok && precision >= 0 {
+				attr.Value = roundNumbers(attr.Value, precision)
+			}
+		}
+		attrs = append(attrs, attr)
+	}
+	e.Attr = attrs
+
+	if e.Name.Local == "style" {
+		var css strings.Builder
+		for _, c := range e.Children {
+			if t, ok := c.(*SVGText); ok {
+				css.WriteString(t.Data)
+			}
+		}
+		e.Children = e.Children[:0]
+		if s := minifyCSS(css.String()); len(s) > 0 {
+			e.Children = append(e.Children, &SVGText{Data: s})
+		}
+		return
+	}
+
+	if _, ok := _svgTextElements[e.Name.Local]; ok {
+		inText = true
+	}
+	e.Children = minifySVGNodes(e.Children, precision, inText)
+}
+
+// isEmptyGroup reports whether e is a <g> element
+// that renders nothing and can't be referenced.
+func isEmptyGroup(e *SVGElement) bool {
+	if e.Name.Local != "g" || len(e.Children) > 0 {
+		return false
+	}
+	_, hasID := e.Attribute("id")
+	return !hasID
+}
+
+var _spaceRe = regexp.MustCompile(`\s+`)
+
+func collapseSpace(s string) string {
+	return _spaceRe.ReplaceAllString(s, " ")
+}
+
+var _numberRe = regexp.MustCompile(`-?(?:\d+\.?\d*|\.\d+)(?:[eE][-+]?\d+)?`)
+
+// roundNumbers rounds all numbers in s to the given number of decimal places.
+func roundNumbers(s string, precision int) string {
+	matches := _numberRe.FindAllStringIndex(s, -1)
+	if len(matches) == 0 {
+		return s
+	}
+
+	scale := math.Pow10(precision)
+	var (
+		sb   strings.Builder
+		last int
+		prev string // previous number written
+	)
+	for _, m := range matches {
+		start, end := m[0], m[1]
+		f, err := strconv.ParseFloat(s[start:end], 64)
+		if err != nil {
+			continue
+		}
+
+		num := formatSVGNumber(math.Round(f*scale) / scale)
+		// Drop the leading zero: "0.5" to ".5", "-0.5" to "-.5".
+		if n, ok := strings.CutPrefix(num, "0."); ok {
+			num = "." + n
+		} else if n, ok := strings.CutPrefix(num, "-0."); ok {
+			num = "-." + n
+		} else if num == "-0" {
+			num = "0"
+		}
+
+		sb.WriteString(s[last:start])
+		// Numbers may be written without a separator, like "1.5.5".
+		// Keep them apart if rounding would merge them.
+		if start > 0 && start == last && needsSeparator(prev, num) {
+			sb.WriteString(" ")
+		}
+		sb.WriteString(num)
+		last, prev = end, num
+	}
+	sb.WriteString(s[last:])
+	return sb.String()
+}
+
+// needsSeparator reports whether next, written right after prev,
+// would be read as part of prev.
+func needsSeparator(prev, next string) bool {
+	switch {
+	case strings.HasPrefix(next, "-"):
+		return false
+	case strings.HasPrefix(next, "."):
+		// ".5" after "1.5" starts a new number, but not after "1".
+		return !strings.ContainsAny(prev, ".eE")
+	default:
+		return true
+	}
+}
+
+// minifyDeclarations minifies a list of CSS declarations
+// like those in a style attribute.
+func minifyDeclarations(style string) string {
+	var decls []string
+	for _, decl := range splitCSS(style, ';') {
+		name, value, ok := strings.Cut(decl, ":")
+		if !ok {
+			continue
+		}
+		name = strings.TrimSpace(name)
+		value = collapseSpace(strings.TrimSpace(value))
+		if len(name) == 0 || len(value) == 0 {
+			continue
+		}
+		decls = append(decls, name+":"+value)
+	}
+	return strings.Join(decls, ";")
+}
+
+// minifyCSS minifies a stylesheet.
+// It removes comments and insignificant whitespace,
+// and removes rules identical to a later rule.
+func minifyCSS(css string) string {
+	var (
+		out     []byte
+		quote   byte
+		pending bool // whitespace seen but not yet written
+	)
+	last := func() byte {
+		if len(out) == 0 {
+			return 0
+		}
+		return out[len(out)-1]
+	}
+
+	for i := 0; i < len(css); i++ {
+		c := css[i]
+		if quote != 0 {
+			out = append(out, c)
+			switch c {
+			case '\\':
+				if i+1 < len(css) {
+					i++
+					out = append(out, css[i])
+				}
+			case quote:
+				quote = 0
+			}
+			continue
+		}
+
+		switch {
+		case c == '/' && strings.HasPrefix(css[i:], "/*"):
+			end := strings.Index(css[i+2:], "*/")
+			if end < 0 {
+				i = len(css)
+			} else {
+				i += end + 3
+			}
+			pending = true
+			continue
+
+		case isCSSSpace(c):
+			pending = true
+			continue
+		}
+
+		if pending && len(out) > 0 && !isCSSPunct(c) && !isCSSPunct(last()) {
+			out = append(out, ' ')
+		}
+		pending = false
+
+		// Drop the ";" before a "}".
+		if c == '}' && last() == ';' {
+			out = out[:len(out)-1]
+		}
+
+		if c == '"' || c == '\'' {
+			quote = c
+		}
+		out = append(out, c)
+	}
+
+	return dedupeCSSRules(string(out))
+}
+
+// dedupeCSSRules removes top-level rules that are repeated later
+// in the stylesheet.
+// The later copy is kept so that the cascade is unchanged.
+func dedupeCSSRules(css string) string {
+	rules := splitCSSRules(css)
+	seen := make(map[string]struct{}, len(rules))
+	kept := make([]string, 0, len(rules))
+	for i := len(rules) - 1; i >= 0; i-- {
+		if _, ok := seen[rules[i]]; ok {
+			continue
+		}
+		seen[rules[i]] = struct{}{}
+		kept = append(kept, rules[i])
+	}
+
+	var sb strings.Builder
+	for i := len(kept) - 1; i >= 0; i-- {
+		sb.WriteString(kept[i])
+	}
+	return sb.String()
+}
+
+// splitCSSRules splits a minified stylesheet into its top-level rules,
+// including at-rules with nested blocks.
+func splitCSSRules(css string) []string {
+	var (
+		rules []string
+		depth int
+		quote byte
+		start int
+	)
+	for i := 0; i < len(css); i++ {
+		c := css[i]
+		if quote != 0 {
+			switch c {
+			case '\\':
+				i++
+			case quote:
+				quote = 0
+			}
+			continue
+		}
+
+		switch c {
+		case '"', '\'':
+			quote = c
+		case '{':
+			depth++
+		case '}':
+			depth--
+			if depth == 0 {
+				rules = append(rules, css[start:i+1])
+				start = i + 1
+			}
+		case ';':
+			// Statements like @import.
+			if depth == 0 {
+				rules = append(rules, css[start:i+1])
+				start = i + 1
+			}
+		}
+	}
+	if start < len(css) {
+		rules = append(rules, css[start:])
+	}
+	return rules
+}
+
+// splitCSS splits s on sep outside of quotes and parentheses.
+func splitCSS(s string, sep byte) []string {
+	var (
+		parts []string
+		quote byte
+		depth int
+		start int
+	)
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if quote != 0 {
+			switch c {
+			case '\\':
+				i++
+			case quote:
+				quote = 0
+			}
+			continue
+		}
+
+		switch c {
+		case '"', '\'':
+			quote = c
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case sep:
+			if depth == 0 {
+				parts = append(parts, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(parts, s[start:])
+}
+
+func isCSSSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == '\f'
+}
+
+// isCSSPunct reports whether whitespace around c is insignificant.
+//
+// ":" is not included because ".a :hover" and ".a:hover"
+// are different selectors.
+func isCSSPunct(c byte) bool {
+	switch c {
+	case '{', '}', ';', ',', '>':
+		return true
+	}
+	return false
+}
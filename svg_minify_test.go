@@ -0,0 +1,245 @@
+package mermaid
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMinifySVG(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		give string
+		tr   MinifySVG
+		want string
+	}{
+		{
+			name: "whitespace",
+			give: "<svg>\n  <g class=\" a  b \">\n    <rect/>\n  </g>\n</svg>",
+			want: `<svg><g class="a b"><rect/></g></svg>`,
+		},
+		{
+			name: "text whitespace",
+			give: "<svg><text>\n  <tspan>a</tspan> <tspan>b  \n c</tspan></text></svg>",
+			want: `<svg><text> <tspan>a</tspan> <tspan>b c</tspan></text></svg>`,
+		},
+		{
+			name: "foreignObject whitespace",
+			give: `<svg><foreignObject><div><span>a</span> <span>b</span></div></foreignObject></svg>`,
+			want: `<svg><foreignObject><div><span>a</span> <span>b</span></div></foreignObject></svg>`,
+		},
+		{
+			name: "comments",
+			give: `<!-- a --><svg><!-- b --><rect/></svg>`,
+			want: `<svg><rect/></svg>`,
+		},
+		{
+			name: "round numbers",
+			give: `<svg viewBox="0 0 85.4375 174.00001" width="100%">` +
+				`<path d="M42.71875,33.5L42.71875,37.66666666666667"/>` +
+				`<g transform="translate(-0.0001, 2.5e-7) scale(0.5)"><rect/></g>` +
+				`<rect x="1.2345" data-value="1.2345"/>` +
+				`</svg>`,
+			want: `<svg viewBox="0 0 85.438 174" width="100%">` +
+				`<path d="M42.719,33.5L42.719,37.667"/>` +
+				`<g transform="translate(0, 0) scale(.5)"><rect/></g>` +
+				`<rect x="1.235" data-value="1.2345"/>` +
+				`</svg>`,
+		},
+		{
+			name: "round numbers/precision",
+			give: `<svg><rect x="1.2345" y="-0.75"/></svg>`,
+			tr:   MinifySVG{Precision: intPtr(1)},
+			want: `<svg><rect x="1.2" y="-.8"/></svg>`,
+		},
+		{
+			name: "round numbers/whole",
+			give: `<svg viewBox="0 0 85.4375 174.5"><rect x="1.2345" y="-0.75"/></svg>`,
+			tr:   MinifySVG{Precision: intPtr(0)},
+			want: `<svg viewBox="0 0 85 175"><rect x="1" y="-1"/></svg>`,
+		},
+		{
+			name: "round numbers/disabled",
+			give: `<svg><rect x="1.23456789"/></svg>`,
+			tr:   MinifySVG{Precision: intPtr(-1)},
+			want: `<svg><rect x="1.23456789"/></svg>`,
+		},
+		{
+			name: "round numbers/no separator",
+			give: `<svg><path d="M1.25.5L1.5.25.75.04"/></svg>`,
+			tr:   MinifySVG{Precision: intPtr(1)},
+			want: `<svg><path d="M1.3.5L1.5.3.8 0"/></svg>`,
+		},
+		{
+			name: "empty groups",
+			give: `<svg><g class="a"><g> </g></g><g id="keep"></g><g><rect/></g></svg>`,
+			want: `<svg><g id="keep"></g><g><rect/></g></svg>`,
+		},
+		{
+			name: "style attribute",
+			give: `<svg><rect style="fill: red ;  stroke:blue; ; "/><g style=" "><rect/></g></svg>`,
+			want: `<svg><rect style="fill:red;stroke:blue"/><g><rect/></g></svg>`,
+		},
+		{
+			name: "stylesheet",
+			give: "<svg><style>\n" +
+				"/* comment */\n" +
+				"#a .b > .c ,\n .d { fill : red ; }\n" +
+				"#a .e :hover{content:\"  ;}  \";}\n" +
+				"@media (max-width: 100px) { .a { fill: red; } }\n" +
+				"</style></svg>",
+			want: `<svg><style>#a .b&gt;.c,.d{fill : red}` +
+				`#a .e :hover{content:"  ;}  "}` +
+				`@media (max-width: 100px){.a{fill: red}}</style></svg>`,
+		},
+		{
+			name: "duplicate style rules",
+			give: `<svg><style>.a{fill:red;}.b{fill:blue;}.a{fill:red;}.c{fill:green}</style></svg>`,
+			want: `<svg><style>.b{fill:blue}.a{fill:red}.c{fill:green}</style></svg>`,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := transformSVG(tt.give, []SVGTransformer{&tt.tr})
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+// TestMinifySVG_golden verifies that minifying the SVGs in our golden tests
+// makes them smaller without changing what they draw.
+func TestMinifySVG_golden(t *testing.T) {
+	t.Parallel()
+
+	svgs := goldenSVGs(t)
+	for i, svg := range svgs {
+		i, svg := i, svg
+		t.Run(strconv.Itoa(i), func(t *testing.T) {
+			t.Parallel()
+
+			want, err := ParseSVG(svg)
+			require.NoError(t, err)
+
+			got, err := transformSVG(svg, []SVGTransformer{new(MinifySVG)})
+			require.NoError(t, err)
+			assert.Less(t, len(got), len(svg), "must be smaller")
+
+			gotDoc, err := ParseSVG(got)
+			require.NoError(t, err)
+
+			wantEls, gotEls := drawnElements(want), drawnElements(gotDoc)
+			require.Len(t, gotEls, len(wantEls))
+			for i, w := range wantEls {
+				assertSameDrawing(t, w, gotEls[i])
+			}
+		})
+	}
+}
+
+// drawnElements lists elements in the document that draw something,
+// skipping groups without children or IDs which MinifySVG may remove.
+func drawnElements(doc *SVGDocument) []*SVGElement {
+	var els []*SVGElement
+	doc.Walk(func(e *SVGElement) {
+		if e.Name.Local == "g" {
+			var drawn bool
+			e.Walk(func(c *SVGElement) {
+				_, hasID := c.Attribute("id")
+				drawn = drawn || hasID || c.Name.Local != "g"
+			})
+			if !drawn {
+				return
+			}
+		}
+		els = append(els, e)
+	})
+	return els
+}
+
+func assertSameDrawing(t *testing.T, want, got *SVGElement) {
+	t.Helper()
+
+	require.Equal(t, want.Name, got.Name)
+	if want.Name.Local == "style" {
+		// Ignore whitespace, trailing semicolons,
+		// and duplicate rules.
+		normalize := func(css string) string {
+			css = strings.Join(strings.Fields(css), "")
+			return dedupeCSSRules(strings.ReplaceAll(css, ";}", "}"))
+		}
+		assert.Equal(t, normalize(want.Text()), normalize(got.Text()))
+		return
+	}
+
+	// Text directly inside the element.
+	// Stylesheets are compared above.
+	text := func(e *SVGElement) string {
+		var sb strings.Builder
+		for _, c := range e.Children {
+			if t, ok := c.(*SVGText); ok {
+				sb.WriteString(t.Data)
+			}
+		}
+		return strings.Join(strings.Fields(sb.String()), " ")
+	}
+	assert.Equal(t, text(want), text(got), "text of <%v>", want.Name.Local)
+
+	for _, attr := range want.Attr {
+		name := attrName(attr.Name)
+		gotValue, ok := got.Attribute(name)
+
+		switch {
+		case name == "style":
+			assert.Equal(t, styleDeclarations(attr.Value), styleDeclarations(gotValue),
+				"style of <%v>", want.Name.Local)
+
+		case !ok:
+			t.Errorf("<%v> lost attribute %v", want.Name.Local, name)
+
+		case name == "class":
+			assert.Equal(t, strings.Fields(attr.Value), strings.Fields(gotValue))
+
+		default:
+			if _, geometry := _svgGeometryAttrs[name]; !geometry {
+				assert.Equal(t, attr.Value, gotValue, "%v of <%v>", name, want.Name.Local)
+				continue
+			}
+
+			wantNums := _numberRe.FindAllString(attr.Value, -1)
+			gotNums := _numberRe.FindAllString(gotValue, -1)
+			require.Len(t, gotNums, len(wantNums), "%v of <%v>", name, want.Name.Local)
+			for i := range wantNums {
+				w, err := strconv.ParseFloat(wantNums[i], 64)
+				require.NoError(t, err)
+				g, err := strconv.ParseFloat(gotNums[i], 64)
+				require.NoError(t, err)
+				assert.LessOrEqual(t, math.Abs(w-g), 0.0005+1e-9, "%v of <%v>", name, want.Name.Local)
+			}
+		}
+	}
+}
+
+func styleDeclarations(style string) map[string]string {
+	decls := make(map[string]string)
+	for _, decl := range strings.Split(style, ";") {
+		name, value, ok := strings.Cut(decl, ":")
+		if !ok {
+			continue
+		}
+		decls[strings.TrimSpace(name)] = strings.Join(strings.Fields(value), " ")
+	}
+	return decls
+}
+
+func intPtr(i int) *int { return &i }
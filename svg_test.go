@@ -0,0 +1,136 @@
+package mermaid
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSVG_roundTrip(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		give string
+		want string // defaults to give
+	}{
+		{
+			name: "simple",
+			give: `<svg id="a" viewBox="0 0 10 10"><g class="b"><rect x="1"/></g></svg>`,
+		},
+		{
+			name: "namespaces",
+			give: `<svg xmlns="http://www.w3.org/2000/svg" xmlns:xlink="http://www.w3.org/1999/xlink"><use xlink:href="#a"/></svg>`,
+		},
+		{
+			name: "escaping",
+			give: `<svg><style>#a&gt;b{content:"&amp;"}</style><text title="&quot;x&quot; &lt; y">a &lt; b</text></svg>`,
+		},
+		{
+			name: "empty elements",
+			give: `<svg><g></g><g/><foreignObject><div><span></span></div></foreignObject></svg>`,
+		},
+		{
+			name: "comments and declarations",
+			give: `<?xml version="1.0" encoding="UTF-8"?><!DOCTYPE svg><svg><!-- hello --></svg>`,
+		},
+		{
+			name: "html",
+			give: `<svg><foreignObject><div>a<br>b&nbsp;c</div></foreignObject></svg>`,
+			want: "<svg><foreignObject><div>a<br>b c</div></foreignObject></svg>",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			doc, err := ParseSVG(tt.give)
+			require.NoError(t, err)
+
+			want := tt.want
+			if len(want) == 0 {
+				want = tt.give
+			}
+			assert.Equal(t, want, doc.String())
+		})
+	}
+}
+
+func TestParseSVG_errors(t *testing.T) {
+	t.Parallel()
+
+	_, err := ParseSVG("")
+	assert.ErrorContains(t, err, "no <svg> element found")
+
+	_, err = ParseSVG(`<div>not svg</div>`)
+	assert.ErrorContains(t, err, "no <svg> element found")
+
+	_, err = ParseSVG(`<svg><g attr="unterminated></svg>`)
+	assert.Error(t, err)
+}
+
+func TestSVGDocument_Root(t *testing.T) {
+	t.Parallel()
+
+	doc, err := ParseSVG(`<?xml version="1.0"?><!-- x --><svg id="a"><svg id="b"/></svg>`)
+	require.NoError(t, err)
+
+	id, ok := doc.Root().Attribute("id")
+	assert.True(t, ok)
+	assert.Equal(t, "a", id)
+}
+
+func TestSVGDocument_Walk(t *testing.T) {
+	t.Parallel()
+
+	doc, err := ParseSVG(`<svg><g><rect/><text>a</text></g><circle/></svg>`)
+	require.NoError(t, err)
+
+	var names []string
+	doc.Walk(func(e *SVGElement) {
+		names = append(names, e.Name.Local)
+	})
+	assert.Equal(t, []string{"svg", "g", "rect", "text", "circle"}, names)
+}
+
+func TestSVGElement_attributes(t *testing.T) {
+	t.Parallel()
+
+	doc, err := ParseSVG(`<svg id="a" xlink:href="#b"/>`)
+	require.NoError(t, err)
+	root := doc.Root()
+
+	href, ok := root.Attribute("xlink:href")
+	assert.True(t, ok)
+	assert.Equal(t, "#b", href)
+
+	_, ok = root.Attribute("href")
+	assert.False(t, ok, "namespace prefix must match")
+
+	root.SetAttribute("id", "c")
+	root.SetAttribute("xml:lang", "en")
+	root.RemoveAttribute("xlink:href")
+	assert.Equal(t, `<svg id="c" xml:lang="en"/>`, doc.String())
+}
+
+func TestSVGElement_Text(t *testing.T) {
+	t.Parallel()
+
+	doc, err := ParseSVG(`<svg><text>a<tspan>b</tspan>c &amp; d</text></svg>`)
+	require.NoError(t, err)
+	assert.Equal(t, "abc & d", doc.Root().Text())
+}
+
+func TestSVGElement_gainsChildren(t *testing.T) {
+	t.Parallel()
+
+	doc, err := ParseSVG(`<svg/>`)
+	require.NoError(t, err)
+
+	root := doc.Root()
+	root.Children = append(root.Children, &SVGText{Data: "x"})
+	assert.Equal(t, `<svg>x</svg>`, doc.String())
+}
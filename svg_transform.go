@@ -0,0 +1,219 @@
+package mermaid
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SVGTransformer modifies SVGs compiled by [ServerRenderer]
+// before they're written to the document.
+//
+// Use one of the built-in transformers
+// [SetSVGAttributes], [RemoveSVGStyles], [FixSVGDimensions],
+// [StripSVGComments], or [MinifySVG],
+// or provide your own with [SVGTransformerFunc].
+type SVGTransformer interface {
+	TransformSVG(doc *SVGDocument) error
+}
+
+// SVGTransformerFunc is an [SVGTransformer] defined as a function.
+type SVGTransformerFunc func(doc *SVGDocument) error
+
+var _ SVGTransformer = SVGTransformerFunc(nil)
+
+// TransformSVG calls the function.
+func (f SVGTransformerFunc) TransformSVG(doc *SVGDocument) error {
+	return f(doc)
+}
+
+// transformSVG parses the SVG, applies the transformers to it,
+// and returns the result.
+func transformSVG(svg string, transformers []SVGTransformer) (string, error) {
+	doc, err := ParseSVG(svg)
+	if err != nil {
+		return "", err
+	}
+
+	for _, t := range transformers {
+		if err := t.TransformSVG(doc); err != nil {
+			return "", err
+		}
+	}
+	return doc.String(), nil
+}
+
+// SetSVGAttributes is an [SVGTransformer] that changes attributes
+// of the root <svg> element.
+//
+//	&mermaid.SetSVGAttributes{
+//		Class:  "diagram",
+//		Remove: []string{"width"},
+//	}
+type SetSVGAttributes struct {
+	// Set holds attributes to add or replace.
+	Set map[string]string
+
+	// Remove lists attributes to remove.
+	Remove []string
+
+	// Class holds space-separated classes
+	// to add to the class attribute.
+	Class string
+}
+
+var _ SVGTransformer = (*SetSVGAttributes)(nil)
+
+// TransformSVG changes attributes of the root <svg> element.
+func (s *SetSVGAttributes) TransformSVG(doc *SVGDocument) error {
+	root := doc.Root()
+	for _, name := range s.Remove {
+		root.RemoveAttribute(name)
+	}
+
+	names := make([]string, 0, len(s.Set))
+	for name := range s.Set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		root.SetAttribute(name, s.Set[name])
+	}
+
+	if classes := strings.Fields(s.Class); len(classes) > 0 {
+		existing, _ := root.Attribute("class")
+		fields := strings.Fields(existing)
+		for _, c := range classes {
+			if !containsString(fields, c) {
+				fields = append(fields, c)
+			}
+		}
+		root.SetAttribute("class", strings.Join(fields, " "))
+	}
+	return nil
+}
+
+// RemoveSVGStyles is an [SVGTransformer] that removes
+// properties from the inline style attributes of SVG elements.
+//
+// For example, to let the page's CSS size the diagram:
+//
+//	&mermaid.RemoveSVGStyles{
+//		Properties: []string{"max-width"},
+//	}
+//
+// Style attributes left empty are removed.
+type RemoveSVGStyles struct {
+	// Properties lists the CSS properties to remove.
+	Properties []string
+
+	// RootOnly restricts this to the root <svg> element.
+	RootOnly bool
+}
+
+var _ SVGTransformer = (*RemoveSVGStyles)(nil)
+
+// TransformSVG removes the style properties.
+func (r *RemoveSVGStyles) TransformSVG(doc *SVGDocument) error {
+	transform := func(e *SVGElement) {
+		style, ok := e.Attribute("style")
+		if !ok {
+			return
+		}
+
+		style = removeStyleProperties(style, r.Properties)
+		if len(style) == 0 {
+			e.RemoveAttribute("style")
+		} else {
+			e.SetAttribute("style", style)
+		}
+	}
+
+	if r.RootOnly {
+		transform(doc.Root())
+	} else {
+		doc.Walk(transform)
+	}
+	return nil
+}
+
+// removeStyleProperties removes the named properties
+// from an inline style declaration.
+func removeStyleProperties(style string, props []string) string {
+	decls := strings.Split(style, ";")
+	kept := decls[:0]
+	for _, decl := range decls {
+		name, _, _ := strings.Cut(decl, ":")
+		name = strings.ToLower(strings.TrimSpace(name))
+		if len(name) == 0 || containsString(props, name) {
+			continue
+		}
+		kept = append(kept, strings.TrimSpace(decl))
+	}
+	if len(kept) == 0 {
+		return ""
+	}
+	return strings.Join(kept, "; ") + ";"
+}
+
+// FixSVGDimensions is an [SVGTransformer] that gives the root <svg> element
+// a fixed width and height based on its viewBox.
+//
+// Mermaid generates SVGs with width="100%" and a max-width style,
+// making diagrams shrink with their container.
+// With this, diagrams are always shown at their natural size.
+var FixSVGDimensions SVGTransformer = SVGTransformerFunc(fixSVGDimensions)
+
+func fixSVGDimensions(doc *SVGDocument) error {
+	resizeSVG(doc, Sizing{Mode: SizeIntrinsic})
+	return nil
+}
+
+// StripSVGComments is an [SVGTransformer] that removes all comments
+// from the SVG.
+var StripSVGComments SVGTransformer = SVGTransformerFunc(stripSVGComments)
+
+func stripSVGComments(doc *SVGDocument) error {
+	doc.Nodes = withoutComments(doc.Nodes)
+	doc.Walk(func(e *SVGElement) {
+		e.Children = withoutComments(e.Children)
+	})
+	return nil
+}
+
+func withoutComments(nodes []SVGNode) []SVGNode {
+	kept := nodes[:0]
+	for _, n := range nodes {
+		if _, ok := n.(*SVGComment); !ok {
+			kept = append(kept, n)
+		}
+	}
+	return kept
+}
+
+// parseViewBox returns the width and height in a viewBox attribute.
+func parseViewBox(viewBox string) (width, height float64, ok bool) {
+	fields := strings.Fields(strings.ReplaceAll(viewBox, ",", " "))
+	if len(fields) != 4 {
+		return 0, 0, false
+	}
+	w, werr := strconv.ParseFloat(fields[2], 64)
+	h, herr := strconv.ParseFloat(fields[3], 64)
+	if werr != nil || herr != nil || w <= 0 || h <= 0 {
+		return 0, 0, false
+	}
+	return w, h, true
+}
+
+func formatSVGNumber(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+func containsString(ss []string, s string) bool {
+	for _, x := range ss {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
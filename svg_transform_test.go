@@ -0,0 +1,109 @@
+package mermaid
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSVGTransformers(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		give string
+		tr   SVGTransformer
+		want string
+	}{
+		{
+			name: "set attributes",
+			give: `<svg id="a" width="100%" class="flowchart"/>`,
+			tr: &SetSVGAttributes{
+				Set:    map[string]string{"role": "img", "id": "b"},
+				Remove: []string{"width"},
+				Class:  "diagram flowchart",
+			},
+			want: `<svg id="b" class="flowchart diagram" role="img"/>`,
+		},
+		{
+			name: "set attributes/no class",
+			give: `<svg/>`,
+			tr:   &SetSVGAttributes{Class: "diagram"},
+			want: `<svg class="diagram"/>`,
+		},
+		{
+			name: "remove styles",
+			give: `<svg style="max-width: 100px; background-color: white;"><g style="max-width:1px"/></svg>`,
+			tr:   &RemoveSVGStyles{Properties: []string{"max-width"}},
+			want: `<svg style="background-color: white;"><g/></svg>`,
+		},
+		{
+			name: "remove styles/root only",
+			give: `<svg style="max-width: 100px;"><g style="max-width:1px"/></svg>`,
+			tr:   &RemoveSVGStyles{Properties: []string{"max-width"}, RootOnly: true},
+			want: `<svg><g style="max-width:1px"/></svg>`,
+		},
+		{
+			name: "fix dimensions",
+			give: `<svg width="100%" style="max-width: 120.5px;" viewBox="-8 -8 120.5 80"/>`,
+			tr:   FixSVGDimensions,
+			want: `<svg width="120.5" viewBox="-8 -8 120.5 80" height="80"/>`,
+		},
+		{
+			name: "fix dimensions/no viewBox",
+			give: `<svg width="100%"/>`,
+			tr:   FixSVGDimensions,
+			want: `<svg width="100%"/>`,
+		},
+		{
+			name: "strip comments",
+			give: `<!-- a --><svg><!-- b --><g><!-- c --><rect/></g></svg>`,
+			tr:   StripSVGComments,
+			want: `<svg><g><rect/></g></svg>`,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			got, err := transformSVG(tt.give, []SVGTransformer{tt.tr})
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, got)
+		})
+	}
+}
+
+func TestTransformSVG_order(t *testing.T) {
+	t.Parallel()
+
+	var calls []string
+	record := func(name string) SVGTransformer {
+		return SVGTransformerFunc(func(doc *SVGDocument) error {
+			calls = append(calls, name)
+			doc.Root().SetAttribute("data-last", name)
+			return nil
+		})
+	}
+
+	got, err := transformSVG(`<svg/>`, []SVGTransformer{record("a"), record("b")})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "b"}, calls)
+	assert.Equal(t, `<svg data-last="b"/>`, got)
+}
+
+func TestTransformSVG_error(t *testing.T) {
+	t.Parallel()
+
+	giveErr := errors.New("great sadness")
+	_, err := transformSVG(`<svg/>`, []SVGTransformer{
+		SVGTransformerFunc(func(*SVGDocument) error { return giveErr }),
+	})
+	assert.ErrorIs(t, err, giveErr)
+
+	_, err = transformSVG(`not an svg`, nil)
+	assert.ErrorContains(t, err, "no <svg> element found")
+}
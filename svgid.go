@@ -0,0 +1,161 @@
+package mermaid
+
+import (
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	// url(#foo) references in attributes.
+	_svgURLRefRe = regexp.MustCompile(`url\(\s*(['"]?)#([^'")\s]+)(['"]?)\s*\)`)
+
+	// #foo selectors and references in stylesheets.
+	_cssIDRe = regexp.MustCompile(`#(-?[_a-zA-Z][_a-zA-Z0-9-]*)`)
+)
+
+// Attributes holding space-separated lists of IDs.
+var _svgIDListAttrs = map[string]struct{}{
+	"aria-labelledby":  {},
+	"aria-describedby": {},
+}
+
+// prefixSVGIDs rewrites all IDs defined in an SVG to start with prefix,
+// along with all references to them:
+// href and xlink:href attributes, url(#...) references,
+// aria-labelledby and aria-describedby attributes,
+// and #id selectors in <style> elements.
+//
+// The ID of the root <svg> element is replaced with prefix.
+// IDs that start with the root ID and a "_" or "-"
+// (as Mermaid's internal IDs do)
+// have that part replaced with prefix.
+// All other IDs are prefixed with prefix and a "-".
+func prefixSVGIDs(doc *SVGDocument, prefix string) {
+	root, _ := doc.Root().Attribute("id")
+	ids := make(map[string]struct{})
+	doc.Walk(func(e *SVGElement) {
+		if id, ok := e.Attribute("id"); ok && len(id) > 0 {
+			ids[id] = struct{}{}
+		}
+	})
+	if len(ids) == 0 {
+		return
+	}
+
+	rename := func(id string) (string, bool) {
+		if _, ok := ids[id]; !ok {
+			return id, false
+		}
+		if len(root) > 0 && strings.HasPrefix(id, root) {
+			// Only if followed by a separator or nothing.
+			if rest := id[len(root):]; rest == "" || rest[0] == '_' || rest[0] == '-' {
+				return prefix + rest, true
+			}
+		}
+		return prefix + "-" + id, true
+	}
+
+	doc.Walk(func(e *SVGElement) {
+		for i, attr := range e.Attr {
+			e.Attr[i].Value = renameAttr(attrName(attr.Name), attr.Value, rename)
+		}
+
+		if e.Name.Local != "style" {
+			return
+		}
+		for _, c := range e.Children {
+			if text, ok := c.(*SVGText); ok {
+				text.Data = _cssIDRe.ReplaceAllStringFunc(text.Data, func(m string) string {
+					id, _ := rename(m[1:])
+					return "#" + id
+				})
+			}
+		}
+	})
+}
+
+// svgIDPrefixes returns the prefixes to use for IDs in the SVGs
+// of diagrams with the given container IDs.
+//
+// Container IDs may hold characters that aren't valid
+// in unescaped CSS #id selectors, like "." or ":",
+// which would break the selectors in the SVG's stylesheet.
+// These are replaced with "-".
+// Prefixes that would collide as a result are made unique.
+func svgIDPrefixes(ids []string) []string {
+	prefixes := make([]string, len(ids))
+	seen := make(map[string]struct{}, len(ids))
+	for i, id := range ids {
+		base := cssIdent(id) + "-svg"
+		prefix := base
+		for n := 2; ; n++ {
+			if _, ok := seen[prefix]; !ok {
+				break
+			}
+			prefix = base + "-" + strconv.Itoa(n)
+		}
+		seen[prefix] = struct{}{}
+		prefixes[i] = prefix
+	}
+	return prefixes
+}
+
+// cssIdent turns s into an identifier matched by _cssIDRe
+// by replacing invalid characters with "-".
+func cssIdent(s string) string {
+	var sb strings.Builder
+	sb.Grow(len(s) + 1)
+	for i, r := range s {
+		switch {
+		case r == '_', 'a' <= r && r <= 'z', 'A' <= r && r <= 'Z':
+		case r == '-' || '0' <= r && r <= '9':
+			// Identifiers can't start with a digit or "--".
+			if i == 0 {
+				sb.WriteByte('_')
+			}
+		default:
+			r = '-'
+			if i == 0 {
+				r = '_'
+			}
+		}
+		sb.WriteRune(r)
+	}
+	return sb.String()
+}
+
+func renameAttr(name, value string, rename func(string) (string, bool)) string {
+	switch name {
+	case "id":
+		id, _ := rename(value)
+		return id
+
+	case "href", "xlink:href":
+		if ref, ok := strings.CutPrefix(value, "#"); ok {
+			id, _ := rename(ref)
+			return "#" + id
+		}
+		return value
+	}
+
+	if _, ok := _svgIDListAttrs[name]; ok {
+		fields := strings.Fields(value)
+		for i, f := range fields {
+			fields[i], _ = rename(f)
+		}
+		return strings.Join(fields, " ")
+	}
+
+	if !strings.Contains(value, "url(") {
+		return value
+	}
+	return _svgURLRefRe.ReplaceAllStringFunc(value, func(m string) string {
+		sub := _svgURLRefRe.FindStringSubmatch(m)
+		id, ok := rename(sub[2])
+		if !ok {
+			return m
+		}
+		return "url(" + sub[1] + "#" + id + sub[3] + ")"
+	})
+}
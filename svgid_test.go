@@ -0,0 +1,96 @@
+package mermaid
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrefixSVGIDs(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name string
+		give string
+		want string
+	}{
+		{
+			name: "mermaid output",
+			give: `<svg id="my-svg" aria-labelledby="chart-title-my-svg">` +
+				`<title id="chart-title-my-svg">T</title>` +
+				`<style>#my-svg{fill:#333;}#my-svg .marker{fill:#333333;}</style>` +
+				`<marker id="my-svg_flowchart-pointEnd"><path/></marker>` +
+				`<g class="node" id="flowchart-A-0"/>` +
+				`<path marker-end="url(#my-svg_flowchart-pointEnd)" style="fill: url('#my-svg_flowchart-pointEnd')"/>` +
+				`</svg>`,
+			want: `<svg id="d1" aria-labelledby="d1-chart-title-my-svg">` +
+				`<title id="d1-chart-title-my-svg">T</title>` +
+				`<style>#d1{fill:#333;}#d1 .marker{fill:#333333;}</style>` +
+				`<marker id="d1_flowchart-pointEnd"><path/></marker>` +
+				`<g class="node" id="d1-flowchart-A-0"/>` +
+				`<path marker-end="url(#d1_flowchart-pointEnd)" style="fill: url('#d1_flowchart-pointEnd')"/>` +
+				`</svg>`,
+		},
+		{
+			name: "href",
+			give: `<svg id="s"><defs><g id="shape"/></defs><use href="#shape"/><use xlink:href="#shape"/><a href="#elsewhere"/></svg>`,
+			want: `<svg id="d1"><defs><g id="d1-shape"/></defs><use href="#d1-shape"/><use xlink:href="#d1-shape"/><a href="#elsewhere"/></svg>`,
+		},
+		{
+			name: "unknown references",
+			give: `<svg id="s"><path fill="url(#gradient)"/><style>#other{}</style></svg>`,
+			want: `<svg id="d1"><path fill="url(#gradient)"/><style>#other{}</style></svg>`,
+		},
+		{
+			name: "no ids",
+			give: `<svg><g/></svg>`,
+			want: `<svg><g/></svg>`,
+		},
+		{
+			name: "text is not rewritten",
+			give: `<svg id="s"><text>#s</text></svg>`,
+			want: `<svg id="d1"><text>#s</text></svg>`,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			doc, err := ParseSVG(tt.give)
+			require.NoError(t, err)
+
+			prefixSVGIDs(doc, "d1")
+			assert.Equal(t, tt.want, doc.String())
+		})
+	}
+}
+
+func TestSVGIDPrefixes(t *testing.T) {
+	t.Parallel()
+
+	got := svgIDPrefixes([]string{
+		"checkout",
+		"a.b",
+		"a:b",
+		"a-b",
+		"1st",
+		"--x",
+		"ns:x_y",
+	})
+	assert.Equal(t, []string{
+		"checkout-svg",
+		"a-b-svg",
+		"a-b-svg-2",
+		"a-b-svg-3",
+		"_1st-svg",
+		"_--x-svg",
+		"ns-x_y-svg",
+	}, got)
+
+	for _, prefix := range got {
+		assert.Equal(t, "#"+prefix, _cssIDRe.FindString("#"+prefix+" .node"), "prefix %q", prefix)
+	}
+}
@@ -0,0 +1,46 @@
+package mermaid
+
+import (
+	_ "embed" // for go:embed
+	"html/template"
+	"strings"
+
+	"github.com/yuin/goldmark/util"
+)
+
+var (
+	//go:embed toolbar.js
+	_toolbarJS string
+
+	//go:embed toolbar.css
+	_toolbarCSS string
+)
+
+// Opening markup for a diagram with a toolbar.
+// The diagram's container follows this.
+const _toolbarOpen = `<div class="mermaid-figure">` +
+	`<div class="mermaid-toolbar" role="toolbar" aria-label="Diagram actions">` +
+	`<button type="button" data-mermaid-action="source" aria-pressed="false">View source</button>` +
+	`<button type="button" data-mermaid-action="copy">Copy source</button>` +
+	`<button type="button" data-mermaid-action="svg">Download SVG</button>` +
+	`<button type="button" data-mermaid-action="png">Download PNG</button>` +
+	`<span class="mermaid-toolbar-status" role="status" aria-live="polite"></span>` +
+	`</div>`
+
+// writeToolbarClose writes the closing markup for a diagram with a toolbar,
+// including the diagram source in a hidden element.
+func writeToolbarClose(w util.BufWriter, source []byte) {
+	_, _ = w.WriteString(`<pre class="mermaid-source" hidden>`)
+	template.HTMLEscape(w, source)
+	_, _ = w.WriteString(`</pre></div>`)
+}
+
+// writeToolbarAssets writes the stylesheet and script
+// that implement the diagram toolbar.
+func writeToolbarAssets(w util.BufWriter) {
+	_, _ = w.WriteString("<style>")
+	_, _ = w.WriteString(_toolbarCSS)
+	_, _ = w.WriteString("</style><script>(")
+	_, _ = w.WriteString(strings.TrimSpace(_toolbarJS))
+	_, _ = w.WriteString(")();</script>")
+}
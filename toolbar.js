@@ -0,0 +1,212 @@
+function () {
+	function figure(button) {
+		return button.closest('.mermaid-figure');
+	}
+
+	function source(fig) {
+		const el = fig.querySelector('.mermaid-source');
+		return el ? el.textContent : '';
+	}
+
+	function filename(fig, ext) {
+		const container = fig.querySelector('[id]');
+		return (container ? container.id : 'diagram') + '.' + ext;
+	}
+
+	function status(fig, message) {
+		const el = fig.querySelector('.mermaid-toolbar-status');
+		if (el) {
+			el.textContent = message;
+		}
+	}
+
+	function svgElement(fig) {
+		for (const svg of fig.querySelectorAll('svg')) {
+			if (!svg.closest('.mermaid-toolbar')) {
+				return svg;
+			}
+		}
+		return null;
+	}
+
+	// Diagrams written as separate files are <img> tags.
+	function imgElement(fig) {
+		for (const img of fig.querySelectorAll('img')) {
+			if (!img.closest('.mermaid-toolbar')) {
+				return img;
+			}
+		}
+		return null;
+	}
+
+	// With SharedStyles, the theme is in a stylesheet outside the SVG.
+	// Returns the rules from it that apply to the SVG.
+	function sharedStyles(svg) {
+		const classes = Array.from(svg.classList)
+			.filter((c) => c.startsWith('mermaid-theme-'))
+			.map((c) => '.' + c);
+		if (!classes.length) {
+			return '';
+		}
+
+		let css = '';
+		for (const sheet of document.styleSheets) {
+			let rules;
+			try {
+				rules = sheet.cssRules;
+			} catch (err) {
+				// Cross-origin stylesheets can't be read.
+				continue;
+			}
+			for (const rule of rules) {
+				if (classes.some((c) => rule.cssText.includes(c))) {
+					css += rule.cssText;
+				}
+			}
+		}
+		return css;
+	}
+
+	function serialize(svg) {
+		const clone = svg.cloneNode(true);
+		// Drop transforms added by pan and zoom.
+		clone.style.transform = '';
+		clone.style.transformOrigin = '';
+		// Keep the theme in the downloaded file.
+		const css = sharedStyles(svg);
+		if (css) {
+			const style = document.createElementNS('http://www.w3.org/2000/svg', 'style');
+			style.textContent = css;
+			clone.insertBefore(style, clone.firstChild);
+		}
+		if (!clone.getAttribute('xmlns')) {
+			clone.setAttribute('xmlns', 'http://www.w3.org/2000/svg');
+		}
+		return new XMLSerializer().serializeToString(clone);
+	}
+
+	function download(name, url) {
+		const a = document.createElement('a');
+		a.href = url;
+		a.download = name;
+		document.body.appendChild(a);
+		a.click();
+		a.remove();
+	}
+
+	function toggleSource(fig, button) {
+		const el = fig.querySelector('.mermaid-source');
+		if (!el) {
+			return;
+		}
+		const show = el.hidden;
+		el.hidden = !show;
+		button.setAttribute('aria-pressed', String(show));
+	}
+
+	async function copySource(fig) {
+		try {
+			await navigator.clipboard.writeText(source(fig));
+			status(fig, 'Copied diagram source.');
+		} catch (err) {
+			status(fig, 'Could not copy diagram source.');
+		}
+	}
+
+	function downloadSVG(fig) {
+		const img = imgElement(fig);
+		if (img) {
+			if (/^data:image\/svg|\.svg$/.test(img.src)) {
+				download(filename(fig, 'svg'), img.src);
+			} else {
+				status(fig, 'Diagram is not available as SVG.');
+			}
+			return;
+		}
+
+		const svg = svgElement(fig);
+		if (!svg) {
+			status(fig, 'Diagram has not been rendered.');
+			return;
+		}
+		const blob = new Blob([serialize(svg)], { type: 'image/svg+xml' });
+		const url = URL.createObjectURL(blob);
+		download(filename(fig, 'svg'), url);
+		setTimeout(() => URL.revokeObjectURL(url), 0);
+	}
+
+	function downloadPNG(fig) {
+		const asset = imgElement(fig);
+		const svg = svgElement(fig);
+		if (!svg && !asset) {
+			status(fig, 'Diagram has not been rendered.');
+			return;
+		}
+
+		let width = 0, height = 0;
+		const viewBox = svg && svg.viewBox && svg.viewBox.baseVal;
+		if (asset) {
+			width = asset.naturalWidth || asset.width;
+			height = asset.naturalHeight || asset.height;
+		} else if (viewBox && viewBox.width && viewBox.height) {
+			width = viewBox.width;
+			height = viewBox.height;
+		} else {
+			const rect = svg.getBoundingClientRect();
+			width = rect.width;
+			height = rect.height;
+		}
+		const scale = 2 * (window.devicePixelRatio || 1);
+
+		const img = new Image();
+		img.onload = () => {
+			const canvas = document.createElement('canvas');
+			canvas.width = Math.ceil(width * scale);
+			canvas.height = Math.ceil(height * scale);
+			const ctx = canvas.getContext('2d');
+			ctx.scale(scale, scale);
+			ctx.drawImage(img, 0, 0, width, height);
+			try {
+				canvas.toBlob((blob) => {
+					const url = URL.createObjectURL(blob);
+					download(filename(fig, 'png'), url);
+					setTimeout(() => URL.revokeObjectURL(url), 0);
+				}, 'image/png');
+			} catch (err) {
+				status(fig, 'Could not convert diagram to PNG.');
+			}
+		};
+		img.onerror = () => status(fig, 'Could not convert diagram to PNG.');
+		if (asset) {
+			img.crossOrigin = 'anonymous';
+			img.src = asset.src;
+		} else {
+			img.src = 'data:image/svg+xml;charset=utf-8,' + encodeURIComponent(serialize(svg));
+		}
+	}
+
+	document.addEventListener('click', (e) => {
+		const button = e.target.closest('.mermaid-toolbar [data-mermaid-action]');
+		if (!button) {
+			return;
+		}
+		const fig = figure(button);
+		if (!fig) {
+			return;
+		}
+		switch (button.getAttribute('data-mermaid-action')) {
+			case 'source':
+				toggleSource(fig, button);
+				break;
+			case 'copy':
+				copySource(fig);
+				break;
+			case 'svg':
+				downloadSVG(fig);
+				break;
+			case 'png':
+				downloadPNG(fig);
+				break;
+		}
+	});
+}
@@ -2,6 +2,9 @@ package mermaid
 
 import (
 	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"strconv"
 
 	"github.com/yuin/goldmark/ast"
 	"github.com/yuin/goldmark/parser"
@@ -14,18 +17,44 @@ import (
 //   - replace mermaid code blocks with mermaid.Block nodes
 //   - add a mermaid.ScriptBlock node if the document uses Mermaid
 //     and one does not already exist
+//   - add a mermaid.StyleBlock node to the start of the document
+//     if SharedStyles is set, the document uses Mermaid,
+//     and one does not already exist
+//
+// Attributes specified on the code block are copied to the Block.
+//
+//	```mermaid {#checkout .wide data-owner="payments"}
+//	graph LR; A-->B;
+//	```
+//
+// Renderers include the id, class, and data-* attributes
+// on the element containing the diagram.
 type Transformer struct {
 	// Don't add a ScriptBlock to the end of the page
 	// even if the page doesn't already have one.
 	NoScript bool
+
+	// SharedStyles adds a StyleBlock to the start of the page
+	// if the page doesn't already have one.
+	// Use this with ServerRenderer.SharedStyles.
+	SharedStyles bool
+
+	// GenerateIDs assigns an id attribute to Blocks
+	// that don't already specify one.
+	//
+	// The ID is derived from a hash of the diagram's contents,
+	// so it remains stable as long as the diagram does not change.
+	// Identical diagrams in the same document receive a numeric suffix.
+	GenerateIDs bool
 }
 
 var _mermaid = []byte("mermaid")
 
 // Transform transforms the provided Markdown AST.
-func (t *Transformer) Transform(doc *ast.Document, reader text.Reader, _ parser.Context) {
+func (t *Transformer) Transform(doc *ast.Document, reader text.Reader, pc parser.Context) {
 	var (
 		hasScript     bool
+		hasStyle      bool
 		mermaidBlocks []*ast.FencedCodeBlock
 	)
 
@@ -36,9 +65,13 @@ func (t *Transformer) Transform(doc *ast.Document, reader text.Reader, _ parser.
 		}
 
 		// For multiple transforms.
-		if _, ok := node.(*ScriptBlock); ok {
+		switch node.(type) {
+		case *ScriptBlock:
 			hasScript = true
 			return ast.WalkContinue, nil
+		case *StyleBlock:
+			hasStyle = true
+			return ast.WalkContinue, nil
 		}
 
 		cb, ok := node.(*ast.FencedCodeBlock)
@@ -60,17 +93,91 @@ func (t *Transformer) Transform(doc *ast.Document, reader text.Reader, _ parser.
 		return
 	}
 
+	ctx := contextFrom(pc)
+	errs := collectedErrorsFrom(pc)
+	blocks := make([]*Block, 0, len(mermaidBlocks))
 	for _, cb := range mermaidBlocks {
-		b := new(Block)
+		b := &Block{ctx: ctx, errs: errs}
 		b.SetLines(cb.Lines())
+		if cb.Info != nil {
+			info := cb.Info.Segment.Value(reader.Source())
+			lang := cb.Language(reader.Source())
+			setFenceAttributes(b, info[len(lang):])
+		}
 
 		parent := cb.Parent()
 		if parent != nil {
 			parent.ReplaceChild(parent, cb, b)
 		}
+		blocks = append(blocks, b)
+	}
+
+	if t.GenerateIDs {
+		generateIDs(blocks, reader.Source())
 	}
 
 	if !hasScript && !t.NoScript {
 		doc.AppendChild(doc, &ScriptBlock{})
 	}
+
+	if !hasStyle && t.SharedStyles {
+		doc.InsertBefore(doc, doc.FirstChild(), &StyleBlock{})
+	}
+}
+
+// setFenceAttributes parses attributes that follow the language name
+// in the info string of a fenced code block, and sets them on b.
+func setFenceAttributes(b *Block, info []byte) {
+	attrs, ok := parser.ParseAttributes(text.NewReader(info))
+	if !ok {
+		return
+	}
+
+	for _, attr := range attrs {
+		b.SetAttribute(attr.Name, attr.Value)
+	}
+}
+
+// generateIDs assigns IDs to blocks without one
+// based on the hash of their contents.
+func generateIDs(blocks []*Block, src []byte) {
+	for i, id := range blockIDs(blocks, src) {
+		if _, ok := blocks[i].Attribute(_attrID); !ok {
+			blocks[i].SetAttribute(_attrID, []byte(id))
+		}
+	}
+}
+
+// blockIDs returns unique IDs for the given blocks.
+// Blocks that specify an ID use that.
+// Others get an ID based on the hash of their contents.
+func blockIDs(blocks []*Block, src []byte) []string {
+	ids := make([]string, len(blocks))
+	seen := make(map[string]struct{}, len(blocks))
+	for i, b := range blocks {
+		if id, ok := attributeString(b, _attrID); ok {
+			ids[i] = id
+			seen[id] = struct{}{}
+		}
+	}
+
+	for i, b := range blocks {
+		if _, ok := b.Attribute(_attrID); ok {
+			continue
+		}
+
+		sum := sha256.Sum256(b.source(src))
+		base := "mermaid-" + hex.EncodeToString(sum[:4])
+		id := base
+		for i := 2; ; i++ {
+			if _, ok := seen[id]; !ok {
+				break
+			}
+			id = base + "-" + strconv.Itoa(i)
+		}
+
+		seen[id] = struct{}{}
+		ids[i] = id
+	}
+	return ids
 }
@@ -152,3 +152,146 @@ func TestTransformer_RepeatedTransformations(t *testing.T) {
 	require.NoError(t, err)
 	assert.Equal(t, 1, scriptCount)
 }
+
+func TestTransformer_SharedStyles(t *testing.T) {
+	t.Parallel()
+
+	parse := func(t *testing.T, src string) *ast.Document {
+		r := text.NewReader([]byte(src))
+		pctx := parser.NewContext()
+		doc := goldmark.New().Parser().
+			Parse(r, parser.WithContext(pctx)).(*ast.Document)
+
+		trans := Transformer{SharedStyles: true}
+		for i := 0; i < 3; i++ {
+			trans.Transform(doc, r, pctx)
+		}
+		return doc
+	}
+
+	countStyles := func(doc ast.Node) (count int) {
+		_ = ast.Walk(doc, func(node ast.Node, enter bool) (ast.WalkStatus, error) {
+			if _, ok := node.(*StyleBlock); ok && enter {
+				count++
+			}
+			return ast.WalkContinue, nil
+		})
+		return count
+	}
+
+	t.Run("mermaid", func(t *testing.T) {
+		t.Parallel()
+
+		doc := parse(t, unlines(
+			"# Title",
+			"",
+			"```mermaid",
+			"foo",
+			"```",
+		))
+		assert.Equal(t, 1, countStyles(doc))
+		assert.IsType(t, &StyleBlock{}, doc.FirstChild())
+	})
+
+	t.Run("no mermaid", func(t *testing.T) {
+		t.Parallel()
+
+		doc := parse(t, "# Title")
+		assert.Zero(t, countStyles(doc))
+	})
+}
+
+func TestTransformer_Attributes(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		desc        string
+		give        string
+		generateIDs bool
+		want        []map[string]string
+	}{
+		{
+			desc: "no attributes",
+			give: unlines(
+				"```mermaid",
+				"foo",
+				"```",
+			),
+			want: []map[string]string{{}},
+		},
+		{
+			desc: "fence attributes",
+			give: unlines(
+				"```mermaid {#checkout .wide .dark data-owner=payments}",
+				"foo",
+				"```",
+			),
+			want: []map[string]string{
+				{
+					"id":         "checkout",
+					"class":      "wide dark",
+					"data-owner": "payments",
+				},
+			},
+		},
+		{
+			desc:        "generated ids",
+			generateIDs: true,
+			give: unlines(
+				"```mermaid",
+				"foo",
+				"```",
+				"",
+				"```mermaid {#explicit}",
+				"foo",
+				"```",
+				"",
+				"```mermaid",
+				"foo",
+				"```",
+				"",
+				"```mermaid",
+				"bar",
+				"```",
+			),
+			want: []map[string]string{
+				{"id": "mermaid-b5bb9d80"},
+				{"id": "explicit"},
+				{"id": "mermaid-b5bb9d80-2"},
+				{"id": "mermaid-7d865e95"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+		t.Run(tt.desc, func(t *testing.T) {
+			t.Parallel()
+
+			p := goldmark.New().Parser()
+			p.AddOptions(
+				parser.WithASTTransformers(
+					util.Prioritized(&Transformer{
+						GenerateIDs: tt.generateIDs,
+					}, 100),
+				),
+			)
+
+			got := p.Parse(text.NewReader([]byte(tt.give)))
+
+			var gotAttrs []map[string]string
+			err := ast.Walk(got, func(node ast.Node, enter bool) (ast.WalkStatus, error) {
+				if b, ok := node.(*Block); ok && enter {
+					attrs := make(map[string]string)
+					for _, attr := range b.Attributes() {
+						attrs[string(attr.Name)] = attributeValue(attr.Value)
+					}
+					gotAttrs = append(gotAttrs, attrs)
+				}
+				return ast.WalkContinue, nil
+			})
+			require.NoError(t, err)
+			assert.Equal(t, tt.want, gotAttrs)
+		})
+	}
+}
@@ -1,9 +1,45 @@
 package mermaid
 
-import "strings"
+import (
+	"os"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
+)
 
 // unlines returns the string formed by joining the provided strings after
 // appending a newline to each.
 func unlines(lines ...string) string {
 	return strings.Join(lines, "\n") + "\n"
 }
+
+var _goldenSVGRe = regexp.MustCompile(`(?s)<svg .*?</svg>`)
+
+// goldenSVGs returns the SVGs generated by Mermaid
+// that are recorded in the golden test files.
+func goldenSVGs(t *testing.T) []string {
+	t.Helper()
+
+	var svgs []string
+	for _, file := range []string{
+		"testdata/server_cli.yaml",
+		"testdata/server_cdp.yaml",
+		"mermaidcdp/testdata/render.yaml",
+	} {
+		data, err := os.ReadFile(file)
+		require.NoError(t, err)
+
+		var tests []struct {
+			Want string `yaml:"want"`
+		}
+		require.NoError(t, yaml.Unmarshal(data, &tests), file)
+		for _, tt := range tests {
+			svgs = append(svgs, _goldenSVGRe.FindAllString(tt.Want, -1)...)
+		}
+	}
+	require.NotEmpty(t, svgs)
+	return svgs
+}